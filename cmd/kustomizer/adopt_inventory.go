@@ -0,0 +1,370 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var adoptInventoryCmd = &cobra.Command{
+	Use:     "inventory",
+	Aliases: []string{"inv"},
+	Short:   "Adopt adds the Kubernetes objects found in the cluster to the given inventory.",
+	Long: `The adopt command selects the live objects matching the given label selector, namespace and kinds,
+sets the inventory ownership labels on them and adds them to the inventory.
+The adopted objects are garbage collected by a subsequent 'apply inventory --prune' if they are missing from the manifests.
+Objects owned by other objects (e.g. ReplicaSets, Pods), objects that belong to a different inventory and
+objects created by the cluster in every namespace (the 'kube-root-ca.crt' ConfigMap, the 'default' ServiceAccount
+and the service account token Secrets) are skipped.`,
+	Example: `  kustomizer adopt inventory <name> -n <namespace> [-l <selector>] [--kinds <kinds>] [--objects-namespace <namespace>]
+
+  # Adopt the Deployments and Services labeled 'app=my-app' from the 'apps' namespace
+  kustomizer adopt inventory my-app -n apps -l app=my-app --kinds Deployment,Service
+
+  # Adopt the objects labeled 'app=my-app' from the 'apps' namespace into an inventory stored in 'kustomizer' namespace
+  kustomizer adopt inventory my-app -n kustomizer -l app=my-app --objects-namespace apps
+
+  # List the objects that would be adopted without making any changes
+  kustomizer adopt inventory my-app -n apps -l app=my-app --dry-run
+`,
+	RunE: runAdoptInventoryCmd,
+}
+
+type adoptInventoryFlags struct {
+	selector         string
+	kinds            []string
+	objectsNamespace string
+	dryRun           bool
+	lockTimeout      time.Duration
+}
+
+var adoptInventoryArgs = newAdoptInventoryFlags()
+
+func newAdoptInventoryFlags() adoptInventoryFlags {
+	return adoptInventoryFlags{
+		lockTimeout: time.Minute,
+	}
+}
+
+func init() {
+	adoptInventoryCmd.Flags().StringVarP(&adoptInventoryArgs.selector, "selector", "l", "",
+		"Label selector to filter the objects on, e.g. 'app.kubernetes.io/part-of=my-app'.")
+	adoptInventoryCmd.Flags().StringSliceVar(&adoptInventoryArgs.kinds, "kinds", nil,
+		"List of Kubernetes API kinds or resources to adopt, e.g. 'Deployment,services,ingresses.networking.k8s.io'. "+
+			"When not specified, all the listable kinds are searched.")
+	adoptInventoryCmd.Flags().StringVar(&adoptInventoryArgs.objectsNamespace, "objects-namespace", "",
+		"The namespace to search for objects, defaults to the inventory namespace.")
+	adoptInventoryCmd.Flags().BoolVar(&adoptInventoryArgs.dryRun, "dry-run", false,
+		"Print the objects that would be adopted without making any changes.")
+	adoptInventoryCmd.Flags().DurationVar(&adoptInventoryArgs.lockTimeout, "lock-timeout", adoptInventoryArgs.lockTimeout,
+		"The length of time to wait for the inventory lock held by another operation to be released.")
+
+	adoptCmd.AddCommand(adoptInventoryCmd)
+}
+
+func runAdoptInventoryCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("you must specify an inventory name")
+	}
+	name := args[0]
+	namespace := *kubeconfigArgs.Namespace
+
+	if adoptInventoryArgs.selector == "" && len(adoptInventoryArgs.kinds) == 0 {
+		return fmt.Errorf("--selector or --kinds is required")
+	}
+
+	selector, err := labels.Parse(adoptInventoryArgs.selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+
+	objectsNamespace := adoptInventoryArgs.objectsNamespace
+	if objectsNamespace == "" {
+		objectsNamespace = namespace
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	resMgr, err := newManager()
+	if err != nil {
+		return err
+	}
+
+	invStorage, err := newInventoryStorage(resMgr)
+	if err != nil {
+		return err
+	}
+
+	logger.Println("discovering API resources...")
+	resources, err := discoverAPIResources(adoptInventoryArgs.kinds)
+	if err != nil {
+		return err
+	}
+
+	objects, err := listAdoptableObjects(ctx, resMgr, resources, objectsNamespace, selector, name, namespace)
+	if err != nil {
+		return err
+	}
+
+	if len(objects) == 0 {
+		return fmt.Errorf("no objects found matching the given selector and kinds")
+	}
+
+	if adoptInventoryArgs.dryRun {
+		for _, object := range objects {
+			rootCmd.Println(`►`, ssa.FmtUnstructured(object), "adopted (dry run)")
+		}
+		return nil
+	}
+
+	newInventory := inventory.NewInventory(name, namespace)
+	lock, err := lockInventory(ctx, invStorage, newInventory, adoptInventoryArgs.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Release(context.Background())
+
+	if err := invStorage.GetInventory(ctx, newInventory); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	logger.Println(fmt.Sprintf("adopting %v object(s)...", len(objects)))
+	for _, object := range objects {
+		if err := setOwnerLabels(ctx, resMgr, object, name, namespace); err != nil {
+			return err
+		}
+		logger.Println(ssa.FmtUnstructured(object), "adopted")
+	}
+
+	if err := newInventory.AddObjects(objects); err != nil {
+		return fmt.Errorf("creating inventory failed, error: %w", err)
+	}
+
+	if err := invStorage.ApplyInventory(ctx, newInventory, false); err != nil {
+		return fmt.Errorf("inventory apply failed, error: %w", err)
+	}
+
+	logger.Println(fmt.Sprintf("inventory %s/%s contains %v object(s)", namespace, name, len(newInventory.Resources)))
+	return nil
+}
+
+// discoverAPIResources returns the listable API resources matching the given kinds,
+// if no kinds are specified, all the listable API resources are returned.
+func discoverAPIResources(kinds []string) ([]metav1.APIResource, error) {
+	discoveryClient, err := kubeconfigArgs.ToDiscoveryClient()
+	if err != nil {
+		return nil, fmt.Errorf("discovery client init failed: %w", err)
+	}
+
+	lists, err := discoveryClient.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("API discovery failed: %w", err)
+	}
+	lists = discovery.FilteredBy(discovery.SupportsAllVerbs{Verbs: []string{"list", "patch"}}, lists)
+
+	var resources []metav1.APIResource
+	found := make(map[string]bool)
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") || isExcludedKind(gv.Group, resource.Kind) {
+				continue
+			}
+
+			matched := len(kinds) == 0
+			for _, kind := range kinds {
+				if strings.EqualFold(kind, resource.Kind) ||
+					strings.EqualFold(kind, resource.Name) ||
+					strings.EqualFold(kind, resource.Name+"."+gv.Group) {
+					matched = true
+					found[kind] = true
+				}
+			}
+
+			if matched {
+				resource.Group = gv.Group
+				resource.Version = gv.Version
+				resources = append(resources, resource)
+			}
+		}
+	}
+
+	for _, kind := range kinds {
+		if !found[kind] {
+			return nil, fmt.Errorf("kind '%s' not found in the cluster API resources", kind)
+		}
+	}
+
+	return resources, nil
+}
+
+// listAdoptableObjects returns the objects matching the given selector,
+// excluding the objects owned by other objects or by a different inventory.
+func listAdoptableObjects(ctx context.Context, resMgr *ssa.ResourceManager, resources []metav1.APIResource,
+	namespace string, selector labels.Selector, invName, invNamespace string) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	ownerLabels := resMgr.GetOwnerLabels(invName, invNamespace)
+
+	for _, resource := range resources {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(schema.GroupVersionKind{
+			Group:   resource.Group,
+			Version: resource.Version,
+			Kind:    resource.Kind + "List",
+		})
+
+		opts := []client.ListOption{client.MatchingLabelsSelector{Selector: selector}}
+		if resource.Namespaced {
+			opts = append(opts, client.InNamespace(namespace))
+		}
+
+		if err := resMgr.Client().List(ctx, list, opts...); err != nil {
+			if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) || apierrors.IsMethodNotSupported(err) {
+				continue
+			}
+			return nil, fmt.Errorf("listing %s failed: %w", resource.Kind, err)
+		}
+
+		for i := range list.Items {
+			object := &list.Items[i]
+			if len(object.GetOwnerReferences()) > 0 || isInventoryStorage(object) || isSystemObject(object) {
+				continue
+			}
+
+			if owned, ok := ownedByOtherInventory(object, ownerLabels); ok {
+				logger.Println(`✗`, ssa.FmtUnstructured(object), "skipped, owned by inventory", owned)
+				continue
+			}
+
+			objects = append(objects, object)
+		}
+	}
+
+	sort.Sort(ssa.SortableUnstructureds(objects))
+	return objects, nil
+}
+
+// setOwnerLabels patches the live object with the inventory ownership labels.
+// A merge patch is used instead of server-side apply, as applying only the labels with the
+// inventory field manager would remove the fields it already owns, e.g. after an import.
+func setOwnerLabels(ctx context.Context, resMgr *ssa.ResourceManager, object *unstructured.Unstructured, name, namespace string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": resMgr.GetOwnerLabels(name, namespace),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	target := &unstructured.Unstructured{}
+	target.SetGroupVersionKind(object.GroupVersionKind())
+	target.SetName(object.GetName())
+	target.SetNamespace(object.GetNamespace())
+	if err := resMgr.Client().Patch(ctx, target, client.RawPatch(types.MergePatchType, patch),
+		client.FieldOwner(inventoryOwner.Field)); err != nil {
+		return fmt.Errorf("%s labeling failed: %w", ssa.FmtUnstructured(object), err)
+	}
+
+	resMgr.SetOwnerLabels([]*unstructured.Unstructured{object}, name, namespace)
+	return nil
+}
+
+// ownedByOtherInventory returns the namespace/name of the inventory
+// if the object is labeled by an inventory other than the given one.
+func ownedByOtherInventory(object *unstructured.Unstructured, ownerLabels map[string]string) (string, bool) {
+	nameKey := inventoryOwner.Group + "/name"
+	namespaceKey := inventoryOwner.Group + "/namespace"
+
+	objLabels := object.GetLabels()
+	name, ok := objLabels[nameKey]
+	if !ok {
+		return "", false
+	}
+
+	if name == ownerLabels[nameKey] && objLabels[namespaceKey] == ownerLabels[namespaceKey] {
+		return "", false
+	}
+
+	return fmt.Sprintf("%s/%s", objLabels[namespaceKey], name), true
+}
+
+// isInventoryStorage returns true if the object is used to store or lock an inventory.
+func isInventoryStorage(object *unstructured.Unstructured) bool {
+	component := object.GetLabels()["app.kubernetes.io/component"]
+	return object.GetLabels()["app.kubernetes.io/created-by"] == inventoryOwner.Field &&
+		(component == inventory.KindName || component == inventory.HistoryKindName)
+}
+
+// isSystemObject returns true for the objects created by the cluster controllers in every namespace,
+// these would be deleted if adopted and pruned afterwards.
+func isSystemObject(object *unstructured.Unstructured) bool {
+	if object.GroupVersionKind().Group != "" {
+		return false
+	}
+
+	switch object.GetKind() {
+	case "ConfigMap":
+		return object.GetName() == "kube-root-ca.crt"
+	case "ServiceAccount":
+		return object.GetName() == "default"
+	case "Secret":
+		secretType, _, _ := unstructured.NestedString(object.Object, "type")
+		return secretType == string(corev1.SecretTypeServiceAccountToken)
+	default:
+		return false
+	}
+}
+
+// isExcludedKind returns true for the kinds that are never managed by an inventory.
+func isExcludedKind(group, kind string) bool {
+	switch {
+	case kind == "Event" && (group == "" || group == "events.k8s.io"):
+		return true
+	case kind == "Lease" && group == "coordination.k8s.io":
+		return true
+	case kind == "EndpointSlice" && group == "discovery.k8s.io":
+		return true
+	case kind == "Endpoints" && group == "":
+		return true
+	default:
+		return false
+	}
+}
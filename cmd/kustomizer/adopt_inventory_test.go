@@ -0,0 +1,190 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestAdoptInventory(t *testing.T) {
+	g := NewWithT(t)
+	id := "adopt-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	legacy := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      id + "-legacy",
+			Namespace: id,
+			Labels: map[string]string{
+				"app": id,
+			},
+		},
+		Data: map[string]string{"key": "value"},
+	}
+	err = envTestClient.Create(context.Background(), legacy)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("lists objects in dry-run mode", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"adopt inv %s -n %s -l app=%s --kinds ConfigMap --dry-run",
+			id,
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s-legacy", id, id)))
+	})
+
+	t.Run("adopts objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"adopt inv %s -n %s -l app=%s --kinds ConfigMap",
+			id,
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(legacy), legacy)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(legacy.GetLabels()).To(HaveKeyWithValue("inventory.kustomizer.dev/name", id))
+		g.Expect(legacy.GetLabels()).To(HaveKeyWithValue("inventory.kustomizer.dev/namespace", id))
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inspect inv %s -n %s",
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s-legacy", id, id)))
+	})
+
+	t.Run("re-adopts applied objects", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id, id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		applied := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: id, Namespace: id}}
+		err = envTestClient.Patch(context.Background(), applied,
+			client.RawPatch(types.MergePatchType, []byte(fmt.Sprintf(`{"metadata":{"labels":{"app":"%s"}}}`, id))))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"adopt inv %s -n %s -l app=%s --kinds ConfigMap",
+			id,
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(applied), applied)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(applied.Data).To(HaveKeyWithValue("key", "test"))
+		g.Expect(applied.GetLabels()).To(HaveKeyWithValue("inventory.kustomizer.dev/name", id))
+	})
+
+	t.Run("prunes adopted objects", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id, id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --prune",
+			id,
+			dir,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(legacy), legacy)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+}
+
+func TestAdoptInventorySystemObjects(t *testing.T) {
+	g := NewWithT(t)
+	id := "adopt-sys-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// the test environment doesn't run the controllers that create these objects
+	for _, object := range []client.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "kube-root-ca.crt", Namespace: id},
+			Data:       map[string]string{"ca.crt": "test"},
+		},
+		&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: id},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "default-token",
+				Namespace:   id,
+				Annotations: map[string]string{corev1.ServiceAccountNameKey: "default"},
+			},
+			Type: corev1.SecretTypeServiceAccountToken,
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: id, Namespace: id},
+			Data:       map[string]string{"key": "value"},
+		},
+	} {
+		err := envTestClient.Create(context.Background(), object)
+		if !apierrors.IsAlreadyExists(err) {
+			g.Expect(err).NotTo(HaveOccurred())
+		}
+	}
+
+	t.Run("skips the objects created by the cluster", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"adopt inv %s -n %s --kinds ConfigMap,ServiceAccount,Secret --dry-run",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s", id, id)))
+		g.Expect(output).NotTo(ContainSubstring("kube-root-ca.crt"))
+		g.Expect(output).NotTo(ContainSubstring("ServiceAccount/"))
+		g.Expect(output).NotTo(ContainSubstring("default-token"))
+	})
+}
@@ -18,14 +18,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"sort"
+	"time"
 
 	"github.com/fluxcd/pkg/ssa"
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/stefanprodan/kustomizer/pkg/inventory"
 	"github.com/stefanprodan/kustomizer/pkg/registry"
@@ -52,6 +58,9 @@ var applyInventoryCmd = &cobra.Command{
   # Force apply a local kustomize overlay then wait for all resources to become ready
   kustomizer apply inventory my-app -n apps -k ./overlays/prod --prune --wait --force
 
+  # Preview the changes, including the objects that would be pruned, without modifying the cluster
+  kustomizer apply inventory my-app -n apps -k ./overlays/prod --prune --dry-run=server
+
   # Apply Kubernetes YAML manifests from a locally cloned Git repository
   kustomizer apply inventory my-app -n apps -f ./deploy/manifests --source="$(git ls-remote --get-url)" --revision="$(git describe --always)"
 `,
@@ -70,9 +79,21 @@ type applyInventoryFlags struct {
 	revision        string
 	createNamespace bool
 	ageIdentities   string
+	lockTimeout     time.Duration
+	dryRun          string
+	output          string
 }
 
-var applyInventoryArgs applyInventoryFlags
+var applyInventoryArgs = newApplyInventoryFlags()
+
+func newApplyInventoryFlags() applyInventoryFlags {
+	return applyInventoryFlags{
+		lockTimeout: time.Minute,
+		dryRun:      "none",
+	}
+}
+
+const serverDryRun = "server"
 
 func init() {
 	applyInventoryCmd.Flags().StringSliceVarP(&applyInventoryArgs.filename, "filename", "f", nil,
@@ -91,6 +112,13 @@ func init() {
 	applyInventoryCmd.Flags().BoolVar(&applyInventoryArgs.createNamespace, "create-namespace", false, "Create the inventory namespace if not present.")
 	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.ageIdentities, "age-identities", "",
 		"Path to a file containing one or more age identities (private keys generated by age-keygen).")
+	applyInventoryCmd.Flags().DurationVar(&applyInventoryArgs.lockTimeout, "lock-timeout", applyInventoryArgs.lockTimeout,
+		"The length of time to wait for the inventory lock held by another operation to be released.")
+	applyInventoryCmd.Flags().StringVar(&applyInventoryArgs.dryRun, "dry-run", applyInventoryArgs.dryRun,
+		"Must be 'none' or 'server'. If 'server', the objects are validated by the API server without being persisted, "+
+			"and the inventory is not modified.")
+	applyInventoryCmd.Flags().StringVarP(&applyInventoryArgs.output, "output", "o", "",
+		"Print a report of the changes in the given format, can be 'json' or 'yaml'.")
 
 	applyCmd.AddCommand(applyInventoryCmd)
 }
@@ -105,6 +133,16 @@ func runApplyInventoryCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("-a, -f or -k is required")
 	}
 
+	switch applyInventoryArgs.dryRun {
+	case "none", serverDryRun:
+	default:
+		return fmt.Errorf("invalid --dry-run value '%s', must be 'none' or 'server'", applyInventoryArgs.dryRun)
+	}
+
+	if err := validateOutputFormat(applyInventoryArgs.output); err != nil {
+		return err
+	}
+
 	identities, err := registry.ParseAgeIdentities(applyInventoryArgs.ageIdentities)
 	if err != nil {
 		return fmt.Errorf("faild to read decryption keys: %w", err)
@@ -119,11 +157,47 @@ func runApplyInventoryCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	objects, hooks, err := splitHooks(objects)
+	if err != nil {
+		return err
+	}
+
 	newInventory := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
 	newInventory.SetSource(applyInventoryArgs.source, applyInventoryArgs.revision, digests)
 	if err := newInventory.AddObjects(objects); err != nil {
 		return fmt.Errorf("creating inventory failed, error: %w", err)
 	}
+
+	var report *changeReport
+	if applyInventoryArgs.dryRun == serverDryRun {
+		report, err = dryRunApplyInventory(ctx, newInventory, objects, hooks, applyInventoryArgs)
+	} else {
+		report, err = applyInventory(ctx, newInventory, objects, hooks, applyInventoryArgs)
+		notify("apply", report, err)
+	}
+
+	if printErr := printReport(cmd.OutOrStdout(), applyInventoryArgs.output, report); printErr != nil && err == nil {
+		return printErr
+	}
+	return err
+}
+
+// applyInventory reconciles the given objects in waves ordered by the apply wave annotation.
+// After the objects are applied, the inventory is recorded in-cluster and
+// the objects missing from the new inventory are pruned in reverse wave order.
+// The pre-apply hooks are run before the first wave and the post-apply hooks after all the objects are applied.
+// The returned report contains the changes performed until an error occurred.
+func applyInventory(ctx context.Context, newInventory *inventory.Inventory, objects []*unstructured.Unstructured,
+	hooks []*unstructured.Unstructured, opts applyInventoryFlags) (*changeReport, error) {
+	report := newChangeReport(newInventory)
+	fail := func(err error) (*changeReport, error) {
+		report.AddError("", err)
+		return report, err
+	}
+
+	// the pre-delete hooks are recorded so that they can be run without pulling the sources
+	newInventory.DeleteHooks = filterHooks(hooks, preDeleteHook)
+
 	logger.Println(fmt.Sprintf("applying %v manifest(s)...", len(objects)))
 
 	for _, object := range objects {
@@ -132,32 +206,57 @@ func runApplyInventoryCmd(cmd *cobra.Command, args []string) error {
 
 	resMgr, err := newManager()
 	if err != nil {
-		return err
+		return fail(err)
 	}
 
-	resMgr.SetOwnerLabels(objects, name, *kubeconfigArgs.Namespace)
+	resMgr.SetOwnerLabels(objects, newInventory.Name, newInventory.Namespace)
 
-	invStorage := &inventory.Storage{
-		Manager: resMgr,
-		Owner:   inventoryOwner,
+	invStorage, err := newInventoryStorage(resMgr)
+	if err != nil {
+		return fail(err)
 	}
 
-	// contains only CRDs and Namespaces
-	var stageOne []*unstructured.Unstructured
+	if opts.createNamespace {
+		if err := invStorage.CreateNamespace(ctx, newInventory.Namespace); err != nil {
+			return fail(err)
+		}
+	}
 
-	// contains all objects except for CRDs and Namespaces
-	var stageTwo []*unstructured.Unstructured
+	// the inventory namespace can be one of the applied objects, in which case
+	// the lock is acquired right after the namespace is created by the first stage of a wave
+	var lock *inventory.Lock
+	defer func() {
+		if lock != nil {
+			lock.Release(context.Background())
+		}
+	}()
+	checkLock := func() error {
+		if lock != nil {
+			return lock.Err()
+		}
 
-	for _, u := range objects {
-		if ssa.IsClusterDefinition(u) {
-			stageOne = append(stageOne, u)
-		} else {
-			stageTwo = append(stageTwo, u)
+		ns := &corev1.Namespace{}
+		if err := resMgr.Client().Get(ctx, client.ObjectKey{Name: newInventory.Namespace}, ns); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
 		}
+
+		l, err := lockInventory(ctx, invStorage, newInventory, opts.lockTimeout)
+		if err != nil {
+			return err
+		}
+		lock = l
+		return nil
+	}
+
+	if err := checkLock(); err != nil {
+		return fail(err)
 	}
 
 	applyOpts := ssa.DefaultApplyOptions()
-	applyOpts.Force = applyInventoryArgs.force
+	applyOpts.Force = opts.force
 	applyOpts.Cleanup = ssa.ApplyCleanupOptions{
 		Annotations: []string{
 			corev1.LastAppliedConfigAnnotation,
@@ -182,81 +281,361 @@ func runApplyInventoryCmd(cmd *cobra.Command, args []string) error {
 
 	waitOpts := ssa.DefaultWaitOptions()
 	waitOpts.Timeout = rootArgs.timeout
-	stageOneChangeSet := &ssa.ChangeSet{}
 
-	if len(stageOne) > 0 {
-		changeSet, err := resMgr.ApplyAll(ctx, stageOne, applyOpts)
+	waves, err := groupByApplyWave(objects)
+	if err != nil {
+		return fail(err)
+	}
+
+	if err := runHooks(ctx, resMgr, hooks, preApplyHook, newInventory.Namespace, report); err != nil {
+		return report, err
+	}
+
+	// the manager of the last applied wave is aware of all the CRDs
+	waveMgr := resMgr
+	for i, wave := range waves {
+		if len(waves) > 1 {
+			logger.Println(fmt.Sprintf("applying wave %d...", wave.wave))
+		}
+
+		waveMgr, err = applyWave(ctx, wave.objects, applyOpts, waitOpts, report, checkLock)
 		if err != nil {
-			return err
+			return report, err
 		}
-		for _, change := range changeSet.Entries {
-			logger.Println(change.String())
+
+		// the next wave is applied only after all the objects in this wave are ready
+		if i < len(waves)-1 {
+			logger.Println(fmt.Sprintf("waiting for wave %d to become ready...", wave.wave))
+			start := time.Now()
+			err := waveMgr.Wait(wave.objects, waitOpts)
+			report.AddWait(fmt.Sprintf("wave %d", wave.wave), len(wave.objects), start, err)
+			if err != nil {
+				return fail(err)
+			}
 		}
-		stageOneChangeSet = changeSet
 	}
 
-	stageTwoMgr, err := newManager()
+	if err := checkLock(); err != nil {
+		return fail(err)
+	}
+	if lock == nil {
+		return fail(fmt.Errorf("namespace %s not found, use --create-namespace or include it in the manifests",
+			newInventory.Namespace))
+	}
+
+	staleObjects, err := applyInventoryStorage(ctx, invStorage, newInventory, opts.createNamespace)
 	if err != nil {
-		return err
+		return fail(err)
 	}
+	report.SetInventory(newInventory)
 
-	if len(stageOneChangeSet.Entries) > 0 {
-		if err := stageTwoMgr.WaitForSet(stageOneChangeSet.ToObjMetadataSet(), waitOpts); err != nil {
-			return err
+	if opts.prune && len(staleObjects) > 0 {
+		if err := checkLock(); err != nil {
+			return fail(err)
+		}
+
+		staleObjects, err = filterPrunableObjects(ctx, waveMgr, staleObjects, false, report)
+		if err != nil {
+			return fail(err)
+		}
+
+		if err := pruneObjects(ctx, waveMgr, staleObjects, waitOpts, report); err != nil {
+			return report, err
 		}
 	}
 
-	sort.Sort(ssa.SortableUnstructureds(stageTwo))
-	for _, object := range stageTwo {
-		change, err := stageTwoMgr.Apply(ctx, object, applyOpts)
+	if opts.wait {
+		logger.Println("waiting for resources to become ready...")
+
+		start := time.Now()
+		err = resMgr.Wait(objects, waitOpts)
+		report.AddWait("ready", len(objects), start, err)
 		if err != nil {
-			return err
+			return fail(err)
+		}
+
+		if opts.prune && len(staleObjects) > 0 {
+			start := time.Now()
+			err = waveMgr.WaitForTermination(staleObjects, waitOpts)
+			report.AddWait("terminated", len(staleObjects), start, err)
+			if err != nil {
+				return fail(fmt.Errorf("wating for termination failed, error: %w", err))
+			}
+		}
+
+		logger.Println("all resources are ready")
+	}
+
+	if err := checkLock(); err != nil {
+		return fail(err)
+	}
+
+	if err := runHooks(ctx, waveMgr, hooks, postApplyHook, newInventory.Namespace, report); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// dryRunApplyInventory validates the objects with a server-side apply dry-run in wave order,
+// and reports the action that would be performed for each object, including the objects that would be pruned.
+// Neither the objects nor the inventory are modified, and hooks are not run.
+func dryRunApplyInventory(ctx context.Context, newInventory *inventory.Inventory, objects []*unstructured.Unstructured,
+	hooks []*unstructured.Unstructured, opts applyInventoryFlags) (*changeReport, error) {
+	const suffix = "(server dry run)"
+	report := newChangeReport(newInventory)
+	fail := func(err error) (*changeReport, error) {
+		report.AddError("", err)
+		return report, err
+	}
+
+	logger.Println(fmt.Sprintf("applying %v manifest(s) %s...", len(objects), suffix))
+
+	for _, object := range objects {
+		fixReplicasConflict(object, objects)
+	}
+
+	resMgr, err := newManager()
+	if err != nil {
+		return fail(err)
+	}
+
+	resMgr.SetOwnerLabels(objects, newInventory.Name, newInventory.Namespace)
+
+	invStorage, err := newInventoryStorage(resMgr)
+	if err != nil {
+		return fail(err)
+	}
+
+	// namespaces and custom resource kinds that don't exist yet,
+	// the objects depending on them can't be validated by the API server
+	pendingNamespaces := make(map[string]bool)
+	pendingKinds := make(map[schema.GroupKind]bool)
+
+	if opts.createNamespace {
+		ns := &corev1.Namespace{}
+		if err := resMgr.Client().Get(ctx, client.ObjectKey{Name: newInventory.Namespace}, ns); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return fail(err)
+			}
+			pendingNamespaces[newInventory.Namespace] = true
+			nsObject := &unstructured.Unstructured{}
+			nsObject.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+			nsObject.SetName(newInventory.Namespace)
+			report.AddChange(newChangeSetEntry(nsObject, ssa.CreatedAction), suffix)
 		}
-		logger.Println(change.String())
 	}
 
-	staleObjects, err := invStorage.GetInventoryStaleObjects(ctx, newInventory)
+	waves, err := groupByApplyWave(objects)
 	if err != nil {
-		return fmt.Errorf("inventory query failed, error: %w", err)
+		return fail(err)
+	}
+
+	for _, hook := range hooks {
+		logger.Println(fmt.Sprintf("%s %s hook skipped %s", ssa.FmtUnstructured(hook), hook.GetAnnotations()[hookAnnotation], suffix))
+	}
+
+	invalid := false
+	for _, wave := range waves {
+		sort.Sort(ssa.SortableUnstructureds(wave.objects))
+		for _, object := range wave.objects {
+			if pendingNamespaces[object.GetNamespace()] || pendingKinds[object.GroupVersionKind().GroupKind()] {
+				report.AddChange(newChangeSetEntry(object, ssa.CreatedAction), suffix)
+				continue
+			}
+
+			change, _, _, err := resMgr.Diff(ctx, object, ssa.DefaultDiffOptions())
+			if err != nil {
+				logger.Println(`✗`, err)
+				report.AddError(ssa.FmtUnstructured(object), err)
+				invalid = true
+				continue
+			}
+			report.AddChange(*change, suffix)
+
+			if change.Action == string(ssa.CreatedAction) {
+				switch {
+				case object.GetKind() == "Namespace" && object.GroupVersionKind().Group == "":
+					pendingNamespaces[object.GetName()] = true
+				case object.GetKind() == "CustomResourceDefinition":
+					group, _, _ := unstructured.NestedString(object.Object, "spec", "group")
+					kind, _, _ := unstructured.NestedString(object.Object, "spec", "names", "kind")
+					pendingKinds[schema.GroupKind{Group: group, Kind: kind}] = true
+				}
+			}
+		}
+	}
+
+	if opts.prune {
+		staleObjects, err := invStorage.GetInventoryStaleObjects(ctx, newInventory)
+		if err != nil {
+			return fail(fmt.Errorf("inventory query failed, error: %w", err))
+		}
+
+		staleObjects, err = filterPrunableObjects(ctx, resMgr, staleObjects, true, report)
+		if err != nil {
+			return fail(err)
+		}
+
+		for _, wave := range groupByDeleteWave(ctx, resMgr.Client(), staleObjects) {
+			for _, object := range wave.objects {
+				err := resMgr.Client().Delete(ctx, object, client.DryRunAll, client.PropagationPolicy(metav1.DeletePropagationBackground))
+				if err != nil {
+					if apierrors.IsNotFound(err) {
+						continue
+					}
+					err = fmt.Errorf("%s delete failed, error: %w", ssa.FmtUnstructured(object), err)
+					logger.Println(`✗`, err)
+					report.AddError(ssa.FmtUnstructured(object), err)
+					invalid = true
+					continue
+				}
+				report.AddPruned(newChangeSetEntry(object, ssa.DeletedAction), suffix)
+			}
+		}
+	}
+
+	if invalid {
+		return report, fmt.Errorf("server dry run failed for inventory %s/%s", newInventory.Namespace, newInventory.Name)
 	}
 
-	err = invStorage.ApplyInventory(ctx, newInventory, applyInventoryArgs.createNamespace)
+	return report, nil
+}
+
+// applyWave reconciles the given objects in two stages, CRDs and Namespaces first,
+// then all the other objects. The stage check runs before each stage and can abort the wave.
+// It returns a resource manager aware of the CRDs applied in the first stage.
+func applyWave(ctx context.Context, objects []*unstructured.Unstructured, applyOpts ssa.ApplyOptions,
+	waitOpts ssa.WaitOptions, report *changeReport, checkStage func() error) (*ssa.ResourceManager, error) {
+	resMgr, err := newManager()
 	if err != nil {
-		return fmt.Errorf("inventory apply failed, error: %w", err)
+		report.AddError("", err)
+		return nil, err
+	}
+
+	// contains only CRDs and Namespaces
+	var stageOne []*unstructured.Unstructured
+
+	// contains all objects except for CRDs and Namespaces
+	var stageTwo []*unstructured.Unstructured
+
+	for _, u := range objects {
+		if ssa.IsClusterDefinition(u) {
+			stageOne = append(stageOne, u)
+		} else {
+			stageTwo = append(stageTwo, u)
+		}
+	}
+
+	stageOneChangeSet := &ssa.ChangeSet{}
+
+	if err := checkStage(); err != nil {
+		report.AddError("", err)
+		return nil, err
 	}
 
-	if applyInventoryArgs.prune && len(staleObjects) > 0 {
-		changeSet, err := stageTwoMgr.DeleteAll(ctx, staleObjects, ssa.DefaultDeleteOptions())
+	if len(stageOne) > 0 {
+		changeSet, err := resMgr.ApplyAll(ctx, stageOne, applyOpts)
 		if err != nil {
-			return fmt.Errorf("prune failed, error: %w", err)
+			report.AddError("", err)
+			return nil, err
 		}
 		for _, change := range changeSet.Entries {
-			logger.Println(change.String())
+			report.AddChange(change)
 		}
+		stageOneChangeSet = changeSet
 	}
 
-	if applyInventoryArgs.wait {
-		logger.Println("waiting for resources to become ready...")
+	stageTwoMgr, err := newManager()
+	if err != nil {
+		report.AddError("", err)
+		return nil, err
+	}
 
-		err = resMgr.Wait(objects, waitOpts)
+	if len(stageOneChangeSet.Entries) > 0 {
+		start := time.Now()
+		err := stageTwoMgr.WaitForSet(stageOneChangeSet.ToObjMetadataSet(), waitOpts)
+		report.AddWait("cluster definitions", len(stageOneChangeSet.Entries), start, err)
 		if err != nil {
-			return err
+			report.AddError("", err)
+			return nil, err
+		}
+	}
+
+	if err := checkStage(); err != nil {
+		report.AddError("", err)
+		return nil, err
+	}
+
+	sort.Sort(ssa.SortableUnstructureds(stageTwo))
+	for _, object := range stageTwo {
+		change, err := stageTwoMgr.Apply(ctx, object, applyOpts)
+		if err != nil {
+			report.AddError(ssa.FmtUnstructured(object), err)
+			return nil, err
 		}
+		report.AddChange(*change)
+	}
 
-		if applyInventoryArgs.prune && len(staleObjects) > 0 {
+	return stageTwoMgr, nil
+}
 
-			err = stageTwoMgr.WaitForTermination(staleObjects, waitOpts)
-			if err != nil {
-				return fmt.Errorf("wating for termination failed, error: %w", err)
+// pruneObjects deletes the stale objects in reverse wave order,
+// waiting for the objects in a wave to be terminated before deleting the next wave.
+func pruneObjects(ctx context.Context, resMgr *ssa.ResourceManager, staleObjects []*unstructured.Unstructured,
+	waitOpts ssa.WaitOptions, report *changeReport) error {
+	waves := groupByDeleteWave(ctx, resMgr.Client(), staleObjects)
+	for i, wave := range waves {
+		changeSet, err := resMgr.DeleteAll(ctx, wave.objects, ssa.DefaultDeleteOptions())
+		if changeSet != nil {
+			for _, change := range changeSet.Entries {
+				report.AddPruned(change)
 			}
 		}
+		if err != nil {
+			err = fmt.Errorf("prune failed, error: %w", err)
+			report.AddError("", err)
+			return err
+		}
 
-		logger.Println("all resources are ready")
+		if i < len(waves)-1 {
+			start := time.Now()
+			err := resMgr.WaitForTermination(wave.objects, waitOpts)
+			report.AddWait(fmt.Sprintf("wave %d terminated", wave.wave), len(wave.objects), start, err)
+			if err != nil {
+				err = fmt.Errorf("wating for termination failed, error: %w", err)
+				report.AddError("", err)
+				return err
+			}
+		}
 	}
-
 	return nil
 }
 
+// applyInventoryStorage computes the stale objects and records the new inventory in-cluster.
+// If the stored inventory is modified by another operation in the meantime,
+// the stale objects are re-computed against the latest inventory.
+func applyInventoryStorage(ctx context.Context, invStorage *inventory.Storage, newInventory *inventory.Inventory, createNamespace bool) ([]*unstructured.Unstructured, error) {
+	const maxAttempts = 3
+	for attempt := 1; ; attempt++ {
+		staleObjects, err := invStorage.GetInventoryStaleObjects(ctx, newInventory)
+		if err != nil {
+			return nil, fmt.Errorf("inventory query failed, error: %w", err)
+		}
+
+		err = invStorage.ApplyInventory(ctx, newInventory, createNamespace)
+		if err == nil {
+			return staleObjects, nil
+		}
+
+		var conflictErr *inventory.ConflictError
+		if !errors.As(err, &conflictErr) || attempt == maxAttempts {
+			return nil, fmt.Errorf("inventory apply failed, error: %w", err)
+		}
+		logger.Println(`✗`, err, "re-computing stale objects...")
+	}
+}
+
 // fixReplicasConflict removes the replicas field from the given workload if it's managed by an HPA
 func fixReplicasConflict(object *unstructured.Unstructured, objects []*unstructured.Unstructured) {
 	for _, hpa := range objects {
@@ -285,3 +664,33 @@ func newManager() (*ssa.ResourceManager, error) {
 
 	return ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner), nil
 }
+
+func newInventoryStorage(resMgr *ssa.ResourceManager) (*inventory.Storage, error) {
+	backend, err := inventory.NewBackend(cfg.Inventory.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inventory.Storage{
+		Manager:      resMgr,
+		Owner:        inventoryOwner,
+		HistoryLimit: *cfg.Inventory.HistoryLimit,
+		Backend:      backend,
+		Compression:  cfg.Inventory.Compression,
+		LockTTL:      cfg.Inventory.LockTTL.Duration,
+		LockErrorHandler: func(err error) {
+			logger.Println(`✗`, err)
+		},
+	}, nil
+}
+
+// lockInventory acquires the lease of the given inventory on behalf of this process.
+func lockInventory(ctx context.Context, invStorage *inventory.Storage, inv *inventory.Inventory, timeout time.Duration) (*inventory.Lock, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = PROJECT
+	}
+	identity := fmt.Sprintf("%s-%d", hostname, os.Getpid())
+
+	return invStorage.LockInventory(ctx, inv, identity, timeout)
+}
@@ -18,15 +18,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+
 	. "github.com/onsi/gomega"
 )
 
@@ -275,3 +281,441 @@ func TestApplyEncryptedArtifact(t *testing.T) {
 		g.Expect(configMap.GetLabels()).To(HaveKeyWithValue("inventory.kustomizer.dev/namespace", id))
 	})
 }
+
+func TestApplySecretStorage(t *testing.T) {
+	g := NewWithT(t)
+	id := "storage-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates inventory in a ConfigMap", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "inv-" + id,
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(configMap.Data).To(HaveKey("resources"))
+	})
+
+	t.Run("migrates inventory to a compressed Secret", func(t *testing.T) {
+		cfg.Inventory.Storage = "Secret"
+		cfg.Inventory.Compression = true
+		defer func() {
+			cfg.Inventory.Storage = "ConfigMap"
+			cfg.Inventory.Compression = false
+		}()
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "inv-" + id,
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(secret), secret)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(secret.Data).To(HaveKey("resources.gz"))
+		g.Expect(secret.GetAnnotations()).To(HaveKeyWithValue("inventory.kustomizer.dev/generation", "2"))
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "inv-" + id,
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inspect inv %s -n %s",
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("Secret/%s/%s", id, id)))
+	})
+}
+
+func TestApplyInventoryConflict(t *testing.T) {
+	g := NewWithT(t)
+	id := "conflict-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = executeCommand(fmt.Sprintf(
+		"apply inv %s -k %s -n %s",
+		id,
+		dir,
+		id,
+	))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	resMgr, err := newManager()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	invStorage, err := newInventoryStorage(resMgr)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	newInventory := inventory.NewInventory(id, id)
+	_, err = invStorage.GetInventoryStaleObjects(context.Background(), newInventory)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(newInventory.ResourceVersion).NotTo(BeEmpty())
+
+	t.Run("fails to write a stale inventory", func(t *testing.T) {
+		_, err = executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		err = invStorage.ApplyInventory(context.Background(), newInventory, false)
+		var conflictErr *inventory.ConflictError
+		g.Expect(errors.As(err, &conflictErr)).To(BeTrue())
+	})
+
+	t.Run("writes the inventory after re-reading it", func(t *testing.T) {
+		_, err = invStorage.GetInventoryStaleObjects(context.Background(), newInventory)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		err = invStorage.ApplyInventory(context.Background(), newInventory, false)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(newInventory.Generation).To(BeEquivalentTo(3))
+	})
+}
+
+func TestApplyWaves(t *testing.T) {
+	g := NewWithT(t)
+	id := "waves-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, []TestFile{
+		{
+			Name: "waves.yaml",
+			Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: %[1]s
+  annotations:
+    kustomizer.dev/apply-wave: "1"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: db
+  namespace: %[1]s
+  annotations:
+    kustomizer.dev/apply-wave: "-1"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: %[1]s
+`, id),
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("applies objects in wave order", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`(?s)wave -1.*ConfigMap/\S+/db created.*wave 0.*ConfigMap/\S+/config created.*wave 1.*ConfigMap/\S+/app created`))
+	})
+
+	t.Run("deletes objects in reverse wave order", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"delete inv %s -n %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`(?s)ConfigMap/\S+/app deleted.*ConfigMap/\S+/config deleted.*ConfigMap/\S+/db deleted`))
+	})
+
+	t.Run("fails for invalid wave", func(t *testing.T) {
+		dir, err := makeTestDir(id, []TestFile{
+			{
+				Name: "waves.yaml",
+				Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: %[1]s
+  annotations:
+    kustomizer.dev/apply-wave: "first"
+`, id),
+			},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s",
+			id,
+			dir,
+			id,
+		))
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestApplyHooks(t *testing.T) {
+	g := NewWithT(t)
+	id := "hooks-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, []TestFile{
+		{
+			Name: "hooks.yaml",
+			Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: %[1]s
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  namespace: %[1]s
+  annotations:
+    kustomizer.dev/hook: pre-apply
+    kustomizer.dev/hook-delete-policy: hook-succeeded
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: migrate
+        image: ghcr.io/stefanprodan/podinfo:v6.0.0
+`, id),
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("runs hooks before applying objects", func(t *testing.T) {
+		// there is no Job controller in the test environment
+		go completeTestJob(id, "migrate")
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`(?s)pre-apply hook Job/\S+/migrate completed.*ConfigMap/\S+/app created`))
+
+		job := &unstructured.Unstructured{}
+		job.SetGroupVersionKind(schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"})
+		job.SetName("migrate")
+		job.SetNamespace(id)
+		g.Eventually(func() bool {
+			err := envTestClient.Get(context.Background(), client.ObjectKeyFromObject(job), job)
+			return apierrors.IsNotFound(err)
+		}, 10*time.Second, time.Second).Should(BeTrue())
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inspect inv %s -n %s",
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).NotTo(MatchRegexp("Job/"))
+	})
+}
+
+// completeTestJob waits for the given Job to be created and marks it as complete.
+func completeTestJob(namespace, name string) {
+	job := &unstructured.Unstructured{}
+	job.SetGroupVersionKind(schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"})
+	job.SetName(name)
+	job.SetNamespace(namespace)
+
+	for i := 0; i < 30; i++ {
+		time.Sleep(time.Second)
+		if err := envTestClient.Get(context.Background(), client.ObjectKeyFromObject(job), job); err != nil {
+			continue
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339)
+		_ = unstructured.SetNestedField(job.Object, map[string]interface{}{
+			"startTime":      now,
+			"completionTime": now,
+			"succeeded":      int64(1),
+			"conditions": []interface{}{
+				map[string]interface{}{
+					"type":               "Complete",
+					"status":             "True",
+					"lastProbeTime":      now,
+					"lastTransitionTime": now,
+				},
+			},
+		}, "status")
+		if err := envTestClient.Status().Update(context.Background(), job); err == nil {
+			return
+		}
+	}
+}
+
+func TestApplyDryRun(t *testing.T) {
+	g := NewWithT(t)
+	id := "dry-run-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	output, err := executeCommand(fmt.Sprintf(
+		"apply inv %s -k %s -n %s",
+		id,
+		dir,
+		id,
+	))
+	g.Expect(err).NotTo(HaveOccurred())
+	t.Logf("\n%s", output)
+
+	t.Run("reports changes without applying them", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id+"-new", id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --prune --dry-run=server",
+			id,
+			dir,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`ConfigMap/%s/%s-new created \(server dry run\)`, id, id)))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`ConfigMap/%s/%s deleted \(server dry run\)`, id, id)))
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id + "-new",
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id,
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inspect inv %s -n %s",
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).NotTo(MatchRegexp(id + "-new"))
+	})
+}
+
+func TestApplyOutput(t *testing.T) {
+	g := NewWithT(t)
+	id := "output-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("prints a json report", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --wait -o json",
+			id,
+			dir,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`"subject": "ConfigMap/%s/%s",\s+"action": "created"`, id, id)))
+		g.Expect(output).To(MatchRegexp(`"phase": "ready"`))
+		g.Expect(output).To(MatchRegexp(`"generation": 1`))
+	})
+
+	t.Run("prints a yaml report of the pruned objects", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id+"-new", id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --prune -o yaml",
+			id,
+			dir,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`action: deleted\s+group: ""\s+kind: ConfigMap\s+subject: ConfigMap/%s/%s\s`, id, id)))
+	})
+
+	t.Run("fails for unsupported formats", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s -o table",
+			id,
+			dir,
+			id,
+		))
+		g.Expect(err).To(HaveOccurred())
+	})
+}
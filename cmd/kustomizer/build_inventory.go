@@ -28,6 +28,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"filippo.io/age"
 	"github.com/fluxcd/pkg/ssa"
@@ -178,7 +179,9 @@ func buildManifests(ctx context.Context, kustomizePath string, filePaths []strin
 				return nil, nil, fmt.Errorf("parsing %s failed: %w", ociURL, err)
 			}
 
+			start := time.Now()
 			yml, meta, err := registry.Pull(ctx, url, identities)
+			metrics.RecordPull(repositoryOf(url), start, len(yml), err)
 			if err != nil {
 				return nil, nil, fmt.Errorf("pulling %s failed: %w", ociURL, err)
 			}
@@ -19,8 +19,7 @@ package main
 import (
 	"context"
 	"fmt"
-	"os"
-	"sort"
+	"time"
 
 	"github.com/stefanprodan/kustomizer/pkg/inventory"
 
@@ -41,13 +40,29 @@ var deleteInventoryCmd = &cobra.Command{
 }
 
 type deleteInventoryFlags struct {
-	wait bool
+	wait        bool
+	noHooks     bool
+	lockTimeout time.Duration
+	output      string
 }
 
-var deleteInventoryArgs deleteInventoryFlags
+var deleteInventoryArgs = newDeleteInventoryFlags()
+
+func newDeleteInventoryFlags() deleteInventoryFlags {
+	return deleteInventoryFlags{
+		wait:        true,
+		lockTimeout: time.Minute,
+	}
+}
 
 func init() {
-	deleteInventoryCmd.Flags().BoolVar(&deleteInventoryArgs.wait, "wait", true, "Wait for the deleted Kubernetes objects to be terminated.")
+	deleteInventoryCmd.Flags().BoolVar(&deleteInventoryArgs.wait, "wait", deleteInventoryArgs.wait, "Wait for the deleted Kubernetes objects to be terminated.")
+	deleteInventoryCmd.Flags().BoolVar(&deleteInventoryArgs.noHooks, "no-hooks", false,
+		"Skip the pre-delete hooks recorded in the inventory.")
+	deleteInventoryCmd.Flags().DurationVar(&deleteInventoryArgs.lockTimeout, "lock-timeout", deleteInventoryArgs.lockTimeout,
+		"The length of time to wait for the inventory lock held by another operation to be released.")
+	deleteInventoryCmd.Flags().StringVarP(&deleteInventoryArgs.output, "output", "o", "",
+		"Print a report of the deleted objects in the given format, can be 'json' or 'yaml'.")
 
 	deleteCmd.AddCommand(deleteInventoryCmd)
 }
@@ -58,9 +73,30 @@ func deleteInventoryCmdRun(cmd *cobra.Command, args []string) error {
 	}
 	name := args[0]
 
+	if err := validateOutputFormat(deleteInventoryArgs.output); err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
+	inv := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
+	report := newChangeReport(inv)
+	err := deleteInventory(ctx, inv, report)
+	if err != nil {
+		report.AddError("", err)
+	}
+	notify("delete", report, err)
+
+	if printErr := printReport(cmd.OutOrStdout(), deleteInventoryArgs.output, report); printErr != nil && err == nil {
+		return printErr
+	}
+	return err
+}
+
+// deleteInventory deletes the objects of the given inventory in reverse wave order, including the inventory storage.
+// The deleted and the protected objects are recorded in the report.
+func deleteInventory(ctx context.Context, inv *inventory.Inventory, report *changeReport) error {
 	logger.Println("retrieving inventory...")
 
 	kubeClient, err := newKubeClient(kubeconfigArgs)
@@ -75,49 +111,83 @@ func deleteInventoryCmdRun(cmd *cobra.Command, args []string) error {
 
 	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
 
-	invStorage := &inventory.Storage{
-		Manager: resMgr,
-		Owner:   inventoryOwner,
+	invStorage, err := newInventoryStorage(resMgr)
+	if err != nil {
+		return err
 	}
 
-	inv := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
+	lock, err := lockInventory(ctx, invStorage, inv, deleteInventoryArgs.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Release(context.Background())
+
 	if err := invStorage.GetInventory(ctx, inv); err != nil {
 		return err
 	}
+	report.SetInventory(inv)
 
 	objects, err := inv.ListObjects()
 	if err != nil {
 		return err
 	}
 
-	logger.Println(fmt.Sprintf("deleting %v manifest(s)...", len(objects)))
-	hasErrors := false
-	sort.Sort(sort.Reverse(ssa.SortableUnstructureds(objects)))
-	for _, object := range objects {
-		change, err := resMgr.Delete(ctx, object, ssa.DefaultDeleteOptions())
-		if err != nil {
-			logger.Println(`✗`, err)
-			hasErrors = true
-			continue
+	if !deleteInventoryArgs.noHooks {
+		if err := runPreDeleteHooks(ctx, resMgr, inv, report); err != nil {
+			return err
 		}
-		logger.Println(change.String())
 	}
 
-	if hasErrors {
-		os.Exit(1)
+	waitOpts := ssa.DefaultWaitOptions()
+	waitOpts.Timeout = rootArgs.timeout
+
+	objects, err = filterPrunableObjects(ctx, resMgr, objects, false, report)
+	if err != nil {
+		return err
+	}
+
+	logger.Println(fmt.Sprintf("deleting %v manifest(s)...", len(objects)))
+	waves := groupByDeleteWave(ctx, resMgr.Client(), objects)
+	for i, wave := range waves {
+		hasErrors := false
+		for _, object := range wave.objects {
+			change, err := resMgr.Delete(ctx, object, ssa.DefaultDeleteOptions())
+			if err != nil {
+				logger.Println(`✗`, err)
+				report.AddError(ssa.FmtUnstructured(object), err)
+				hasErrors = true
+				continue
+			}
+			report.AddChange(*change)
+		}
+
+		if hasErrors {
+			return fmt.Errorf("failed to delete all the objects in inventory %s/%s", inv.Namespace, inv.Name)
+		}
+
+		// the previous wave is deleted only after all the objects in this wave are terminated
+		if i < len(waves)-1 {
+			logger.Println(fmt.Sprintf("waiting for wave %d to be terminated...", wave.wave))
+			start := time.Now()
+			err := resMgr.WaitForTermination(wave.objects, waitOpts)
+			report.AddWait(fmt.Sprintf("wave %d terminated", wave.wave), len(wave.objects), start, err)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	if err := invStorage.DeleteInventory(ctx, inv); err != nil {
 		return err
 	}
 
-	logger.Println(fmt.Sprintf("ConfigMap/%s/%s deleted", *kubeconfigArgs.Namespace, name))
+	logger.Println(fmt.Sprintf("%s/%s/%s deleted", invStorage.Kind(), inv.Namespace, inv.Name))
 
 	if deleteInventoryArgs.wait {
-		waitOpts := ssa.DefaultWaitOptions()
-		waitOpts.Timeout = rootArgs.timeout
 		logger.Println("waiting for resources to be terminated...")
+		start := time.Now()
 		err = resMgr.WaitForTermination(objects, waitOpts)
+		report.AddWait("terminated", len(objects), start, err)
 		if err != nil {
 			return err
 		}
@@ -126,3 +196,12 @@ func deleteInventoryCmdRun(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// runPreDeleteHooks runs the pre-delete hooks recorded in the inventory.
+func runPreDeleteHooks(ctx context.Context, resMgr *ssa.ResourceManager, inv *inventory.Inventory, report *changeReport) error {
+	if len(inv.DeleteHooks) == 0 {
+		return nil
+	}
+
+	return runHooks(ctx, resMgr, inv.DeleteHooks, preDeleteHook, inv.Namespace, report)
+}
@@ -97,3 +97,152 @@ func TestDelete(t *testing.T) {
 		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
 	})
 }
+
+func TestDeleteProtected(t *testing.T) {
+	g := NewWithT(t)
+	id := "protected-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, []TestFile{
+		{
+			Name: "protected.yaml",
+			Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: disabled
+  namespace: %[1]s
+  annotations:
+    kustomizer.dev/prune: disabled
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: orphan
+  namespace: %[1]s
+  annotations:
+    kustomizer.dev/prune: orphan
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: deleted
+  namespace: %[1]s
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: protected
+  namespace: %[1]s
+`, id),
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	output, err := executeCommand(fmt.Sprintf(
+		"apply inv %s -f %s -n %s",
+		id,
+		dir,
+		id,
+	))
+	g.Expect(err).NotTo(HaveOccurred())
+	t.Logf("\n%s", output)
+
+	t.Run("deletes unprotected objects", func(t *testing.T) {
+		cfg.Prune.ProtectedKinds = []string{"Secret"}
+		defer func() {
+			cfg.Prune.ProtectedKinds = []string{}
+		}()
+
+		output, err := executeCommand(fmt.Sprintf(
+			"delete inv %s -n %s",
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		configMap := &corev1.ConfigMap{}
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: "deleted", Namespace: id}, configMap)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: "disabled", Namespace: id}, configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(configMap.GetLabels()).To(HaveKeyWithValue("inventory.kustomizer.dev/name", id))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: "orphan", Namespace: id}, configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(configMap.GetLabels()).NotTo(HaveKey("inventory.kustomizer.dev/name"))
+
+		secret := &corev1.Secret{}
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: "protected", Namespace: id}, secret)
+		g.Expect(err).NotTo(HaveOccurred())
+	})
+}
+
+func TestDeleteHooks(t *testing.T) {
+	g := NewWithT(t)
+	id := "del-hooks-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, []TestFile{
+		{
+			Name: "hooks.yaml",
+			Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: %[1]s
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: cleanup
+  namespace: %[1]s
+  annotations:
+    kustomizer.dev/hook: pre-delete
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: cleanup
+        image: ghcr.io/stefanprodan/podinfo:v6.0.0
+`, id),
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("records the hooks without running them", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).NotTo(MatchRegexp("hook"))
+	})
+
+	t.Run("runs the recorded hooks before deleting objects", func(t *testing.T) {
+		// there is no Job controller in the test environment
+		go completeTestJob(id, "cleanup")
+
+		output, err := executeCommand(fmt.Sprintf(
+			"delete inv %s -n %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`(?s)pre-delete hook Job/\S+/cleanup completed.*ConfigMap/\S+/app deleted`))
+	})
+}
@@ -17,7 +17,30 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/kustomizer/pkg/diff"
+)
+
+const (
+	// diffIgnoreAnnotation is a comma separated list of JSON pointers
+	// to the object fields that are excluded from the diff and drift detection.
+	diffIgnoreAnnotation = "kustomizer.dev/diff-ignore"
+
+	// diffIgnoreManagersAnnotation is a comma separated list of field managers
+	// whose fields are excluded from the diff and drift detection.
+	diffIgnoreManagersAnnotation = "kustomizer.dev/diff-ignore-managers"
 )
 
 var diffCmd = &cobra.Command{
@@ -28,3 +51,204 @@ var diffCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(diffCmd)
 }
+
+// validateColorMode returns an error if the colour mode is not supported.
+func validateColorMode(mode string) error {
+	switch mode {
+	case "", "auto", "always", "never":
+		return nil
+	default:
+		return fmt.Errorf("unsupported color mode '%s', can be 'auto', 'always' or 'never'", mode)
+	}
+}
+
+// printUnifiedDiff writes the unified diff to the given writer, in colour if the mode is 'always',
+// or if the mode is 'auto' and the writer is a terminal.
+func printUnifiedDiff(w io.Writer, unified, colorMode string) {
+	useColor := colorMode == "always"
+	if colorMode == "" || colorMode == "auto" {
+		if f, ok := w.(*os.File); ok {
+			useColor = term.IsTerminal(int(f.Fd()))
+		}
+	}
+
+	if useColor {
+		unified = diff.Colorize(unified)
+	}
+	fmt.Fprint(w, unified)
+}
+
+// diffObject compares the object with its in-cluster state using a server-side apply dry-run.
+// If the object drifted, it returns the live and the merged objects, with the Secrets data
+// and the fields listed in the config diff mask rules masked, unless showSecrets is set.
+func diffObject(ctx context.Context, resMgr *ssa.ResourceManager, object *unstructured.Unstructured, showSecrets bool) (
+	*ssa.ChangeSetEntry, *unstructured.Unstructured, *unstructured.Unstructured, error) {
+	change, liveObject, mergedObject, err := driftObject(ctx, resMgr, object)
+	if err != nil || change.Action != string(ssa.ConfiguredAction) {
+		return change, liveObject, mergedObject, err
+	}
+
+	if !showSecrets {
+		maskObjects(liveObject, mergedObject)
+	}
+
+	return change, liveObject, mergedObject, nil
+}
+
+// driftObject compares the object with its in-cluster state using a server-side apply dry-run,
+// the fields matching the config diff ignore rules and the object ignore annotations are excluded.
+// If the object drifted, it returns the live and the merged objects.
+func driftObject(ctx context.Context, resMgr *ssa.ResourceManager, object *unstructured.Unstructured) (
+	*ssa.ChangeSetEntry, *unstructured.Unstructured, *unstructured.Unstructured, error) {
+	change, liveObject, mergedObject, err := resMgr.Diff(ctx, object, ssa.DefaultDiffOptions())
+	if err != nil || change.Action != string(ssa.ConfiguredAction) {
+		return change, liveObject, mergedObject, err
+	}
+
+	paths, managers, err := getIgnoreRules(object)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// the resource manager replaces the Secrets data with its own masks and removes the managed fields,
+	// the objects are retrieved again to reveal which keys changed and to find the fields owners
+	if isSecret(object) || len(paths) > 0 || len(managers) > 0 {
+		liveObject, mergedObject, err = dryRunDiff(ctx, resMgr.Client(), object)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	if len(paths) > 0 || len(managers) > 0 {
+		if err := ignoreFields(liveObject, mergedObject, paths, managers); err != nil {
+			return nil, nil, nil, fmt.Errorf("%s ignore rules failed, error: %w", ssa.FmtUnstructured(object), err)
+		}
+		if !hasDrifted(liveObject, mergedObject) {
+			change.Action = string(ssa.UnchangedAction)
+			return change, nil, nil, nil
+		}
+	}
+
+	unstructured.RemoveNestedField(liveObject.Object, "metadata", "managedFields")
+	unstructured.RemoveNestedField(mergedObject.Object, "metadata", "managedFields")
+	return change, liveObject, mergedObject, nil
+}
+
+// dryRunDiff returns the in-cluster object and the result of a server-side apply dry-run of the given object.
+func dryRunDiff(ctx context.Context, kubeClient client.Client, object *unstructured.Unstructured) (
+	*unstructured.Unstructured, *unstructured.Unstructured, error) {
+	liveObject := &unstructured.Unstructured{}
+	liveObject.SetGroupVersionKind(object.GroupVersionKind())
+	if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(object), liveObject); err != nil {
+		return nil, nil, fmt.Errorf("%s query failed, error: %w", ssa.FmtUnstructured(object), err)
+	}
+
+	mergedObject := object.DeepCopy()
+	if err := kubeClient.Patch(ctx, mergedObject, client.Apply, client.DryRunAll,
+		client.ForceOwnership, client.FieldOwner(inventoryOwner.Field)); err != nil {
+		return nil, nil, fmt.Errorf("%s dry-run failed, error: %w", ssa.FmtUnstructured(object), err)
+	}
+
+	return liveObject, mergedObject, nil
+}
+
+// getIgnoreRules returns the JSON pointers and the field managers excluded from the diff of the given object,
+// from the config diff ignore rules matching its kind and from the object annotations.
+func getIgnoreRules(object *unstructured.Unstructured) ([]string, []string, error) {
+	var paths, managers []string
+	for _, rule := range cfg.Diff.Ignore {
+		if rule.Matches(object.GetKind()) {
+			paths = append(paths, rule.Paths...)
+			managers = append(managers, rule.Managers...)
+		}
+	}
+
+	annotations := object.GetAnnotations()
+	for _, path := range splitAnnotation(annotations[diffIgnoreAnnotation]) {
+		if _, err := diff.ParsePointer(path); err != nil {
+			return nil, nil, fmt.Errorf("%s has an invalid %s annotation: %w",
+				ssa.FmtUnstructured(object), diffIgnoreAnnotation, err)
+		}
+		paths = append(paths, path)
+	}
+	managers = append(managers, splitAnnotation(annotations[diffIgnoreManagersAnnotation])...)
+
+	return paths, managers, nil
+}
+
+// ignoreFields removes from both objects the fields matching the given JSON pointers
+// and the fields owned by the given managers in the live object.
+func ignoreFields(liveObject, mergedObject *unstructured.Unstructured, paths, managers []string) error {
+	entries := liveObject.GetManagedFields()
+	for _, object := range []*unstructured.Unstructured{liveObject, mergedObject} {
+		var pointers []string
+		for _, path := range paths {
+			pointers = append(pointers, diff.Expand(object.Object, path)...)
+		}
+
+		for _, entry := range entries {
+			if !containsString(managers, entry.Manager) || entry.FieldsV1 == nil {
+				continue
+			}
+			managed, err := diff.ManagedPaths(object.Object, entry.FieldsV1.Raw)
+			if err != nil {
+				return err
+			}
+			pointers = append(pointers, managed...)
+		}
+
+		diff.Remove(object.Object, pointers...)
+	}
+	return nil
+}
+
+// hasDrifted compares the labels, the annotations and the content of the objects
+// without the metadata and the status, the same way the resource manager does.
+func hasDrifted(liveObject, mergedObject *unstructured.Unstructured) bool {
+	if !apiequality.Semantic.DeepEqual(liveObject.GetLabels(), mergedObject.GetLabels()) {
+		return true
+	}
+
+	if !apiequality.Semantic.DeepEqual(liveObject.GetAnnotations(), mergedObject.GetAnnotations()) {
+		return true
+	}
+
+	live := liveObject.DeepCopy()
+	merged := mergedObject.DeepCopy()
+	for _, object := range []*unstructured.Unstructured{live, merged} {
+		unstructured.RemoveNestedField(object.Object, "metadata")
+		unstructured.RemoveNestedField(object.Object, "status")
+	}
+	return !apiequality.Semantic.DeepEqual(live.Object, merged.Object)
+}
+
+func splitAnnotation(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// maskObjects masks the Secrets data and the fields matching the config diff mask rules in both objects.
+func maskObjects(liveObject, mergedObject *unstructured.Unstructured) {
+	kind := mergedObject.GetKind()
+
+	var paths []string
+	if isSecret(mergedObject) {
+		paths = append(paths, diff.SecretPaths...)
+	}
+	for _, rule := range cfg.Diff.Mask {
+		if rule.Matches(kind) {
+			paths = append(paths, rule.Paths...)
+		}
+	}
+
+	diff.MaskFields(liveObject.Object, mergedObject.Object, paths)
+}
+
+func isSecret(object *unstructured.Unstructured) bool {
+	return object.GetKind() == "Secret" && object.GroupVersionKind().Group == ""
+}
@@ -19,20 +19,26 @@ package main
 import (
 	"context"
 	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/fluxcd/pkg/ssa"
 	"github.com/spf13/cobra"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
 	"sigs.k8s.io/yaml"
 
+	"github.com/stefanprodan/kustomizer/pkg/diff"
 	"github.com/stefanprodan/kustomizer/pkg/registry"
 )
 
 var diffArtifactCmd = &cobra.Command{
 	Use:   "artifact",
 	Short: "Diff compares the two artifacts and prints the differences between the Kubernetes resources to stdout.",
+	Long: `The diff command pulls the two artifacts, matches their Kubernetes objects by kind, namespace and name,
+and prints the added, removed and modified objects along with their diffs.
+The differences between the artifacts source, revision, checksum and encryption are printed first.`,
 	Example: `  kustomizer diff artifact <oci url1> <oci url2>
 
   # Diff artifact by tag
@@ -40,67 +46,255 @@ var diffArtifactCmd = &cobra.Command{
 
   # Diff artifact by digest
   kustomizer diff artifact oci://registry/org/repo@sha245:<digest-1> oci://registry/org/repo@sha245:<digest-2>
+
+  # Print the changed objects and fields as JSON
+  kustomizer diff artifact oci://registry/org/repo:v1 oci://registry/org/repo:v2 -o json
 `,
 	RunE: runDiffArtifactCmd,
 }
 
 type diffArtifactFlags struct {
 	ageIdentities string
+	color         string
+	output        string
+	showSecrets   bool
 }
 
-var diffArtifactArgs diffArtifactFlags
+var diffArtifactArgs = newDiffArtifactFlags()
+
+func newDiffArtifactFlags() diffArtifactFlags {
+	return diffArtifactFlags{
+		color: "auto",
+	}
+}
 
 func init() {
 	diffArtifactCmd.Flags().StringVar(&diffArtifactArgs.ageIdentities, "age-identities", "",
 		"Path to a file containing one or more age identities (private keys generated by age-keygen).")
+	diffArtifactCmd.Flags().StringVar(&diffArtifactArgs.color, "color", diffArtifactArgs.color,
+		"Colorize the diff, can be 'auto', 'always' or 'never'.")
+	diffArtifactCmd.Flags().StringVarP(&diffArtifactArgs.output, "output", "o", "",
+		"Print a report of the differences in the given format, can be 'json' or 'yaml'.")
+	diffArtifactCmd.Flags().BoolVar(&diffArtifactArgs.showSecrets, "show-secrets", false,
+		"Print the Secrets data and the fields listed in the config diff mask rules instead of masking them.")
 
 	diffCmd.AddCommand(diffArtifactCmd)
 }
 
+// The actions recorded for the objects that differ between two sets of manifests.
+const (
+	addedAction    = "added"
+	removedAction  = "removed"
+	modifiedAction = "modified"
+)
+
+// artifactDiffReport is the machine-readable result of comparing two artifacts.
+type artifactDiffReport struct {
+	// From holds the metadata of the first artifact.
+	From *registry.Metadata `json:"from"`
+
+	// To holds the metadata of the second artifact.
+	To *registry.Metadata `json:"to"`
+
+	// Metadata holds the differences between the artifacts metadata.
+	Metadata []metadataChange `json:"metadata,omitempty"`
+
+	// Changes holds the objects that were added, removed or modified.
+	Changes []reportChange `json:"changes"`
+
+	// Unchanged is the number of objects that are the same in both artifacts.
+	Unchanged int `json:"unchanged"`
+}
+
+type metadataChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// objectDiff holds the differences between two revisions of a Kubernetes object.
+type objectDiff struct {
+	change  reportChange
+	unified string
+}
+
 func runDiffArtifactCmd(cmd *cobra.Command, args []string) error {
 	if len(args) != 2 {
 		return fmt.Errorf("you must specify two artifact URLs")
 	}
 
-	identities, err := registry.ParseAgeIdentities(diffArtifactArgs.ageIdentities)
-	if err != nil {
-		return fmt.Errorf("faild to read decryption keys: %w", err)
+	if err := validateOutputFormat(diffArtifactArgs.output); err != nil {
+		return err
 	}
 
-	tmpDir, err := os.MkdirTemp("", *kubeconfigArgs.Namespace)
-	if err != nil {
+	if err := validateColorMode(diffArtifactArgs.color); err != nil {
 		return err
 	}
-	defer os.RemoveAll(tmpDir)
+
+	identities, err := registry.ParseAgeIdentities(diffArtifactArgs.ageIdentities)
+	if err != nil {
+		return fmt.Errorf("faild to read decryption keys: %w", err)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
-	files := []string{}
+	var objects [2][]*unstructured.Unstructured
+	var metas [2]*registry.Metadata
 	for i, ociURL := range args {
 		url, err := registry.ParseURL(ociURL)
 		if err != nil {
 			return err
 		}
 
-		data, _, err := registry.Pull(ctx, url, identities)
+		data, meta, err := registry.Pull(ctx, url, identities)
 		if err != nil {
 			return fmt.Errorf("pulling %s failed: %w", url, err)
 		}
-		res, _ := yaml.Marshal(data)
-		resPath := filepath.Join(tmpDir, fmt.Sprintf("%d.yaml", i))
-		if err := os.WriteFile(resPath, res, 0644); err != nil {
-			return err
+
+		objects[i], err = ssa.ReadObjects(strings.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("reading %s failed: %w", url, err)
 		}
-		files = append(files, resPath)
+		metas[i] = meta
 	}
 
-	out, _ := exec.Command("diff", "-N", "-u", files[0], files[1]).Output()
-	for i, line := range strings.Split(string(out), "\n") {
-		if i > 1 && len(line) > 0 {
-			rootCmd.Println(line)
-		}
+	diffs, unchanged, err := diffObjectSets(objects[0], objects[1], diffArtifactArgs.showSecrets)
+	if err != nil {
+		return err
+	}
+
+	report := &artifactDiffReport{
+		From:      metas[0],
+		To:        metas[1],
+		Metadata:  diffMetadata(metas[0], metas[1]),
+		Changes:   []reportChange{},
+		Unchanged: unchanged,
+	}
+	for _, d := range diffs {
+		report.Changes = append(report.Changes, d.change)
 	}
 
+	if diffArtifactArgs.output != "" {
+		return printReport(cmd.OutOrStdout(), diffArtifactArgs.output, report)
+	}
+
+	for _, change := range report.Metadata {
+		rootCmd.Println(`►`, fmt.Sprintf("%s changed from '%s' to '%s'", change.Field, change.Old, change.New))
+	}
+
+	counts := make(map[string]int)
+	for _, d := range diffs {
+		counts[d.change.Action]++
+		rootCmd.Println(`►`, d.change.Subject, d.change.Action)
+		printUnifiedDiff(cmd.OutOrStdout(), d.unified, diffArtifactArgs.color)
+	}
+
+	rootCmd.Println(fmt.Sprintf("%d added, %d removed, %d modified, %d unchanged",
+		counts[addedAction], counts[removedAction], counts[modifiedAction], unchanged))
+
 	return nil
 }
+
+// diffObjectSets matches the objects by ID and returns the added, removed and modified objects in apply order,
+// along with the number of unchanged objects. The Secrets data and the fields listed in the config diff mask rules
+// are masked in the returned diffs, unless showSecrets is set.
+func diffObjectSets(from, to []*unstructured.Unstructured, showSecrets bool) ([]objectDiff, int, error) {
+	fromObjects := make(map[string]*unstructured.Unstructured, len(from))
+	for _, o := range from {
+		fromObjects[object.UnstructuredToObjMetadata(o).String()] = o
+	}
+
+	toObjects := make(map[string]*unstructured.Unstructured, len(to))
+	all := make([]*unstructured.Unstructured, 0, len(from)+len(to))
+	for _, o := range to {
+		toObjects[object.UnstructuredToObjMetadata(o).String()] = o
+		all = append(all, o)
+	}
+	for id, o := range fromObjects {
+		if _, ok := toObjects[id]; !ok {
+			all = append(all, o)
+		}
+	}
+	sort.Sort(ssa.SortableUnstructureds(all))
+
+	var diffs []objectDiff
+	unchanged := 0
+	for _, o := range all {
+		id := object.UnstructuredToObjMetadata(o).String()
+		fromObject, inFrom := fromObjects[id]
+		toObject, inTo := toObjects[id]
+
+		action := modifiedAction
+		switch {
+		case !inFrom:
+			action = addedAction
+			fromObject = &unstructured.Unstructured{Object: map[string]interface{}{}}
+		case !inTo:
+			action = removedAction
+			toObject = &unstructured.Unstructured{Object: map[string]interface{}{}}
+		case apiequality.Semantic.DeepEqual(fromObject.Object, toObject.Object):
+			unchanged++
+			continue
+		}
+
+		fromObject, toObject = fromObject.DeepCopy(), toObject.DeepCopy()
+		fromObject.SetGroupVersionKind(o.GroupVersionKind())
+		toObject.SetGroupVersionKind(o.GroupVersionKind())
+		if !showSecrets {
+			maskObjects(fromObject, toObject)
+		}
+
+		change := newReportChange(newChangeSetEntry(o, ssa.Action(action)))
+		fromYAML, toYAML := "", ""
+		if inFrom {
+			data, err := yaml.Marshal(fromObject)
+			if err != nil {
+				return nil, 0, err
+			}
+			fromYAML = string(data)
+		}
+		if inTo {
+			data, err := yaml.Marshal(toObject)
+			if err != nil {
+				return nil, 0, err
+			}
+			toYAML = string(data)
+		}
+		if inFrom && inTo {
+			change.Fields = diff.Fields(fromObject.Object, toObject.Object)
+		}
+
+		diffs = append(diffs, objectDiff{
+			change:  change,
+			unified: diff.Unified(fromYAML, toYAML, diff.DefaultContext),
+		})
+	}
+
+	return diffs, unchanged, nil
+}
+
+// diffMetadata returns the differences between the source, revision, checksum and encryption of two artifacts.
+func diffMetadata(from, to *registry.Metadata) []metadataChange {
+	encryption := func(m *registry.Metadata) string {
+		if m.Encrypted == "" {
+			return "none"
+		}
+		return m.Encrypted
+	}
+
+	fields := []metadataChange{
+		{Field: "source", Old: from.SourceURL, New: to.SourceURL},
+		{Field: "revision", Old: from.SourceRevision, New: to.SourceRevision},
+		{Field: "checksum", Old: from.Checksum, New: to.Checksum},
+		{Field: "encryption", Old: encryption(from), New: encryption(to)},
+	}
+
+	var changes []metadataChange
+	for _, field := range fields {
+		if field.Old != field.New {
+			changes = append(changes, field)
+		}
+	}
+	return changes
+}
@@ -63,5 +63,22 @@ func TestDiffArtifact(t *testing.T) {
 		g.Expect(err).NotTo(HaveOccurred())
 		t.Logf("\n%s", output)
 		g.Expect(output).To(MatchRegexp("immutable"))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`Secret/%s/%s modified`, id, id)))
+		g.Expect(output).To(MatchRegexp(`0 added, 0 removed, 1 modified, 2 unchanged`))
+		g.Expect(output).ToNot(MatchRegexp(`key: "\d+"`))
+	})
+
+	t.Run("generates json report", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"diff artifact %s %s -o json",
+			artifact1,
+			artifact2,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`"field": "checksum"`))
+		g.Expect(output).To(MatchRegexp(`"action": "modified"`))
+		g.Expect(output).To(MatchRegexp(`"path": "/immutable"`))
 	})
 }
@@ -20,15 +20,17 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
+	"filippo.io/age"
 	"github.com/fluxcd/pkg/ssa"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/yaml"
 
+	"github.com/stefanprodan/kustomizer/pkg/diff"
 	"github.com/stefanprodan/kustomizer/pkg/inventory"
 	"github.com/stefanprodan/kustomizer/pkg/registry"
 )
@@ -50,6 +52,12 @@ var diffInventoryCmd = &cobra.Command{
 
   # Build the inventory from a local overlay and print the YAML diff
   kustomizer diff inventory my-app -n apps -k ./overlays/prod
+
+  # Compare a local overlay with the artifacts of the inventory revision 3
+  kustomizer diff inventory my-app -n apps -k ./overlays/prod --against 3
+
+  # Compare a local overlay with an OCI artifact, without access to the cluster
+  kustomizer diff inventory my-app -n apps -k ./overlays/prod --against oci://registry/org/repo:latest
 `,
 	RunE: runDiffInventoryCmd,
 }
@@ -61,9 +69,30 @@ type diffInventoryFlags struct {
 	patch         []string
 	prune         bool
 	ageIdentities string
+	output        string
+	color         string
+	showSecrets   bool
+	against       string
+	exitCode      bool
+	failOn        []string
+	maxChanges    int
+}
+
+func newDiffInventoryFlags() diffInventoryFlags {
+	return diffInventoryFlags{
+		color:      "auto",
+		maxChanges: -1,
+	}
 }
 
-var diffInventoryArgs diffInventoryFlags
+var diffInventoryArgs = newDiffInventoryFlags()
+
+// The change types accepted by the diff inventory '--fail-on' flag.
+const (
+	failOnCreate = "create"
+	failOnDrift  = "drift"
+	failOnDelete = "delete"
+)
 
 func init() {
 	diffInventoryCmd.Flags().StringSliceVarP(&diffInventoryArgs.filename, "filename", "f", nil,
@@ -77,6 +106,22 @@ func init() {
 	diffInventoryCmd.Flags().BoolVar(&diffInventoryArgs.prune, "prune", false, "Delete stale objects from the cluster.")
 	diffInventoryCmd.Flags().StringVar(&diffInventoryArgs.ageIdentities, "age-identities", "",
 		"Path to a file containing one or more age identities (private keys generated by age-keygen).")
+	diffInventoryCmd.Flags().StringVarP(&diffInventoryArgs.output, "output", "o", "",
+		"Print a report of the changes in the given format instead of the YAML diff, can be 'json' or 'yaml'.")
+	diffInventoryCmd.Flags().StringVar(&diffInventoryArgs.color, "color", diffInventoryArgs.color,
+		"Colorize the YAML diff, can be 'auto', 'always' or 'never'.")
+	diffInventoryCmd.Flags().BoolVar(&diffInventoryArgs.showSecrets, "show-secrets", false,
+		"Print the Secrets data and the fields listed in the config diff mask rules instead of masking them.")
+	diffInventoryCmd.Flags().StringVar(&diffInventoryArgs.against, "against", "",
+		"Compare the manifests with an inventory revision number or an OCI artifact URL instead of the cluster state.")
+	diffInventoryCmd.Flags().BoolVar(&diffInventoryArgs.exitCode, "exit-code", false,
+		fmt.Sprintf("Exit with code %d if any object would be created, modified or deleted.", driftExitCode))
+	diffInventoryCmd.Flags().StringSliceVar(&diffInventoryArgs.failOn, "fail-on", nil,
+		fmt.Sprintf("Exit with code %d if any object would be changed in the given ways, can be '%s', '%s' or '%s'.",
+			driftExitCode, failOnCreate, failOnDrift, failOnDelete))
+	diffInventoryCmd.Flags().IntVar(&diffInventoryArgs.maxChanges, "max-changes", diffInventoryArgs.maxChanges,
+		fmt.Sprintf("Exit with code %d if more than the given number of objects would be changed, a negative value disables the limit.",
+			driftExitCode))
 
 	diffCmd.AddCommand(diffInventoryCmd)
 }
@@ -91,6 +136,23 @@ func runDiffInventoryCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("-a, -f or -k is required")
 	}
 
+	if err := validateOutputFormat(diffInventoryArgs.output); err != nil {
+		return err
+	}
+
+	if err := validateColorMode(diffInventoryArgs.color); err != nil {
+		return err
+	}
+
+	for _, action := range diffInventoryArgs.failOn {
+		switch action {
+		case failOnCreate, failOnDrift, failOnDelete:
+		default:
+			return fmt.Errorf("unsupported --fail-on value '%s', can be '%s', '%s' or '%s'",
+				action, failOnCreate, failOnDrift, failOnDelete)
+		}
+	}
+
 	identities, err := registry.ParseAgeIdentities(diffInventoryArgs.ageIdentities)
 	if err != nil {
 		return fmt.Errorf("faild to read decryption keys: %w", err)
@@ -104,8 +166,19 @@ func runDiffInventoryCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	// hooks run once per apply or delete and are not part of the inventory objects,
+	// the pre-delete hooks are recorded separately, so they are excluded from the diff
+	objects, _, err = splitHooks(objects)
+	if err != nil {
+		return err
+	}
+
 	sort.Sort(ssa.SortableUnstructureds(objects))
 
+	if diffInventoryArgs.against != "" {
+		return diffInventoryAgainst(ctx, cmd, name, objects, identities)
+	}
+
 	newInventory := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
 	if err := newInventory.AddObjects(objects); err != nil {
 		return fmt.Errorf("creating inventory failed, error: %w", err)
@@ -123,58 +196,52 @@ func runDiffInventoryCmd(cmd *cobra.Command, args []string) error {
 
 	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
 
-	invStorage := &inventory.Storage{
-		Manager: resMgr,
-		Owner:   inventoryOwner,
+	invStorage, err := newInventoryStorage(resMgr)
+	if err != nil {
+		return err
 	}
 
 	resMgr.SetOwnerLabels(objects, name, *kubeconfigArgs.Namespace)
 
-	if _, err := exec.LookPath("diff"); err != nil {
-		return fmt.Errorf("diff binary not found in PATH, error: %w", err)
-	}
-
-	tmpDir, err := os.MkdirTemp("", *kubeconfigArgs.Namespace)
-	if err != nil {
-		return err
-	}
-	defer os.RemoveAll(tmpDir)
+	report := newChangeReport(newInventory)
+	structured := diffInventoryArgs.output != ""
 
 	invalid := false
 	for _, object := range objects {
-		change, liveObject, mergedObject, err := resMgr.Diff(ctx, object, ssa.DefaultDiffOptions())
+		change, liveObject, mergedObject, err := diffObject(ctx, resMgr, object, diffInventoryArgs.showSecrets)
 		if err != nil {
 			logger.Println(`✗`, err)
+			report.AddError(ssa.FmtUnstructured(object), err)
 			invalid = true
 			continue
 		}
 
-		if change.Action == string(ssa.CreatedAction) {
-			rootCmd.Println(`►`, change.Subject, "created")
+		if change.Action != string(ssa.ConfiguredAction) {
+			report.RecordChange(*change)
+			if !structured && change.Action == string(ssa.CreatedAction) {
+				rootCmd.Println(`►`, change.Subject, "created")
+			}
+			continue
 		}
 
-		if change.Action == string(ssa.ConfiguredAction) {
-			rootCmd.Println(`►`, change.Subject, "drifted")
+		report.RecordDiff(*change, diff.Fields(liveObject.Object, mergedObject.Object))
+		if structured {
+			continue
+		}
 
-			liveYAML, _ := yaml.Marshal(liveObject)
-			liveFile := filepath.Join(tmpDir, "live.yaml")
-			if err := os.WriteFile(liveFile, liveYAML, 0644); err != nil {
-				return err
-			}
+		rootCmd.Println(`►`, change.Subject, "drifted")
 
-			mergedYAML, _ := yaml.Marshal(mergedObject)
-			mergedFile := filepath.Join(tmpDir, "merged.yaml")
-			if err := os.WriteFile(mergedFile, mergedYAML, 0644); err != nil {
-				return err
-			}
+		liveYAML, err := yaml.Marshal(liveObject)
+		if err != nil {
+			return err
+		}
 
-			out, _ := exec.Command("diff", "-N", "-u", liveFile, mergedFile).Output()
-			for i, line := range strings.Split(string(out), "\n") {
-				if i > 1 && len(line) > 0 {
-					rootCmd.Println(line)
-				}
-			}
+		mergedYAML, err := yaml.Marshal(mergedObject)
+		if err != nil {
+			return err
 		}
+
+		printUnifiedDiff(cmd.OutOrStdout(), diff.Unified(string(liveYAML), string(mergedYAML), diff.DefaultContext), diffInventoryArgs.color)
 	}
 
 	if !invalid {
@@ -184,12 +251,143 @@ func runDiffInventoryCmd(cmd *cobra.Command, args []string) error {
 		}
 
 		for _, object := range staleObjects {
-			rootCmd.Println(`►`, fmt.Sprintf("%s deleted", ssa.FmtUnstructured(object)))
+			report.RecordChange(newChangeSetEntry(object, ssa.DeletedAction))
+			if !structured {
+				rootCmd.Println(`►`, fmt.Sprintf("%s deleted", ssa.FmtUnstructured(object)))
+			}
 		}
 	}
 
+	if err := printReport(cmd.OutOrStdout(), diffInventoryArgs.output, report); err != nil {
+		return err
+	}
+
 	if invalid {
 		os.Exit(1)
 	}
-	return nil
+	return checkDiffGates(report)
+}
+
+// diffInventoryAgainst compares the objects with the ones built from the artifacts of an inventory revision
+// or from an OCI artifact, without reading the objects from the cluster.
+func diffInventoryAgainst(ctx context.Context, cmd *cobra.Command, name string, objects []*unstructured.Unstructured,
+	identities []age.Identity) error {
+	againstObjects, err := buildAgainstObjects(ctx, name, diffInventoryArgs.against, identities)
+	if err != nil {
+		return err
+	}
+
+	diffs, _, err := diffObjectSets(againstObjects, objects, diffInventoryArgs.showSecrets)
+	if err != nil {
+		return err
+	}
+
+	newInventory := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
+	if err := newInventory.AddObjects(objects); err != nil {
+		return fmt.Errorf("creating inventory failed, error: %w", err)
+	}
+
+	report := newChangeReport(newInventory)
+	for _, d := range diffs {
+		change := d.change
+		switch change.Action {
+		case addedAction:
+			change.Action = string(ssa.CreatedAction)
+		case removedAction:
+			change.Action = string(ssa.DeletedAction)
+		case modifiedAction:
+			change.Action = string(ssa.ConfiguredAction)
+		}
+		report.Changes = append(report.Changes, change)
+
+		if diffInventoryArgs.output == "" {
+			rootCmd.Println(`►`, change.Subject, change.Action)
+			printUnifiedDiff(cmd.OutOrStdout(), d.unified, diffInventoryArgs.color)
+		}
+	}
+
+	if err := printReport(cmd.OutOrStdout(), diffInventoryArgs.output, report); err != nil {
+		return err
+	}
+	return checkDiffGates(report)
+}
+
+// buildAgainstObjects returns the objects of the given inventory revision number or OCI artifact URL.
+// For revisions, the inventory history is read from the cluster and the objects are built from the
+// recorded artifacts digests.
+func buildAgainstObjects(ctx context.Context, name, against string, identities []age.Identity) ([]*unstructured.Unstructured, error) {
+	artifacts := []string{against}
+	if !strings.HasPrefix(against, registry.URLPrefix) {
+		generation, err := strconv.ParseInt(against, 10, 64)
+		if err != nil || generation < 1 {
+			return nil, fmt.Errorf("invalid value '%s' for --against, must be a revision number or an OCI URL", against)
+		}
+
+		resMgr, err := newManager()
+		if err != nil {
+			return nil, err
+		}
+
+		invStorage, err := newInventoryStorage(resMgr)
+		if err != nil {
+			return nil, err
+		}
+
+		revision := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
+		if err := invStorage.GetInventoryRevision(ctx, revision, generation); err != nil {
+			return nil, err
+		}
+
+		if len(revision.Artifacts) == 0 {
+			return nil, fmt.Errorf("revision %d has no artifacts, only revisions applied from OCI artifacts can be compared",
+				generation)
+		}
+
+		artifacts = nil
+		for _, digest := range revision.Artifacts {
+			artifacts = append(artifacts, registry.URLPrefix+digest)
+		}
+		logger.Println(fmt.Sprintf("building inventory from revision %d...", generation))
+	}
+
+	objects, _, err := buildManifests(ctx, "", nil, artifacts, nil, identities)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, _, err = splitHooks(objects)
+	return objects, err
+}
+
+// checkDiffGates returns an exit error if the changes are not allowed by the '--exit-code',
+// '--fail-on' and '--max-changes' flags.
+func checkDiffGates(report *changeReport) error {
+	counts := map[string]int{
+		failOnCreate: report.Count(string(ssa.CreatedAction)),
+		failOnDrift:  report.Count(string(ssa.ConfiguredAction)),
+		failOnDelete: report.Count(string(ssa.DeletedAction)),
+	}
+	total := counts[failOnCreate] + counts[failOnDrift] + counts[failOnDelete]
+	summary := fmt.Sprintf("%v created, %v drifted, %v deleted", counts[failOnCreate], counts[failOnDrift], counts[failOnDelete])
+
+	var failed []string
+	for _, action := range diffInventoryArgs.failOn {
+		if counts[action] > 0 {
+			failed = append(failed, action)
+		}
+	}
+
+	var err error
+	switch {
+	case diffInventoryArgs.maxChanges >= 0 && total > diffInventoryArgs.maxChanges:
+		err = fmt.Errorf("%v changes exceed the maximum of %v: %s", total, diffInventoryArgs.maxChanges, summary)
+	case len(failed) > 0:
+		err = fmt.Errorf("changes not allowed by --fail-on=%s detected: %s", strings.Join(failed, ","), summary)
+	case diffInventoryArgs.exitCode && total > 0:
+		err = fmt.Errorf("changes detected in inventory %s/%s: %s", report.Inventory.Namespace, report.Inventory.Name, summary)
+	default:
+		return nil
+	}
+
+	return &exitError{code: driftExitCode, err: err}
 }
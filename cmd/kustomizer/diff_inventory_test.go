@@ -17,10 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
 	. "github.com/onsi/gomega"
+
+	"github.com/stefanprodan/kustomizer/pkg/config"
 )
 
 func TestDiffInventory(t *testing.T) {
@@ -89,4 +92,162 @@ func TestDiffInventory(t *testing.T) {
 		t.Logf("\n%s", output)
 		g.Expect(output).To(MatchRegexp("immutable"))
 	})
+
+	t.Run("generates json report", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id+"-1", id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s --prune -o json",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`"subject": "ConfigMap/%s/%s-1",\s+"action": "created"`, id, id)))
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`"subject": "ConfigMap/%s/%s",\s+"action": "deleted"`, id, id)))
+		g.Expect(output).ToNot(MatchRegexp("►"))
+	})
+
+	t.Run("generates field diff", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id, id, true))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s -o json",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`"path": "/immutable",\s+"type": "changed",\s+"old": false,\s+"new": true`))
+	})
+
+	t.Run("generates colored diff", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id, id, true))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s --color always",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(ContainSubstring("\x1b[32m+immutable: true"))
+	})
+
+	t.Run("masks secret values", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id, id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`-\s+key: '\*\*\* \(before\)'`))
+		g.Expect(output).To(MatchRegexp(`\+\s+key: '\*\*\* \(after\)'`))
+	})
+
+	t.Run("shows secret values", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id, id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s --show-secrets",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`Secret/%s/%s drifted`, id, id)))
+		g.Expect(output).ToNot(MatchRegexp(`\*\*\*`))
+	})
+
+	t.Run("ignores configured fields", func(t *testing.T) {
+		cfg.Diff.Ignore = []config.IgnoreRule{{Kind: "Secret", Paths: []string{"/data/key"}}}
+		defer func() {
+			cfg.Diff.Ignore = []config.IgnoreRule{}
+		}()
+
+		dir, err := makeTestDir(id, testManifests(id, id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).ToNot(MatchRegexp(fmt.Sprintf(`Secret/%s/%s drifted`, id, id)))
+	})
+
+	t.Run("compares against artifact", func(t *testing.T) {
+		artifact := fmt.Sprintf("oci://%s/%s:%s", registryHost, id, "v1")
+		_, err := executeCommand(fmt.Sprintf(
+			"push artifact %s -k %s",
+			artifact,
+			dir,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		dirImmutable, err := makeTestDir(id, testManifests(id, id, true))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s --against %s -o json",
+			id,
+			dirImmutable,
+			id,
+			artifact,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`"action": "configured"`))
+		g.Expect(output).To(MatchRegexp(`"path": "/immutable",\s+"type": "changed",\s+"old": false,\s+"new": true`))
+	})
+
+	t.Run("exits with code on changes", func(t *testing.T) {
+		dir, err := makeTestDir(id, testManifests(id, id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s --fail-on=delete",
+			id,
+			dir,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff inv %s -k %s -n %s --max-changes=0",
+			id,
+			dir,
+			id,
+		))
+		g.Expect(err).To(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		var exitErr *exitError
+		g.Expect(errors.As(err, &exitErr)).To(BeTrue())
+		g.Expect(exitErr.code).To(Equal(driftExitCode))
+		g.Expect(err.Error()).To(ContainSubstring("0 created, 1 drifted, 0 deleted"))
+	})
 }
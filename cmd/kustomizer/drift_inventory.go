@@ -0,0 +1,265 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/cli-utils/pkg/object"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+	"github.com/stefanprodan/kustomizer/pkg/registry"
+)
+
+var driftInventoryCmd = &cobra.Command{
+	Use:     "inventory",
+	Aliases: []string{"inv"},
+	Short:   "Drift compares the objects built from the OCI artifacts recorded in the given inventory with the cluster state.",
+	Long: `The drift command pulls the OCI artifacts by the digests recorded in the inventory,
+builds the desired state and compares it with the cluster state using a server-side apply dry-run.
+It reports the objects that were modified (drifted), the objects that were deleted (missing)
+and the objects labeled as owned by the inventory but not recorded in it (extra).
+Only the inventories applied exclusively from OCI artifacts, without local manifests or patches, can be checked.
+
+The command exits with code 0 if no drift is detected, 2 if drift is detected, and 1 on errors.`,
+	Example: `  kustomizer drift inventory <name> -n <namespace> [-o json]
+
+  # Check an inventory for drift
+  kustomizer drift inventory my-app -n apps
+
+  # Check an inventory applied from encrypted OCI artifacts and print a JSON report
+  kustomizer drift inventory my-app -n apps --age-identities ./keys/id.txt -o json
+`,
+	RunE: runDriftInventoryCmd,
+}
+
+type driftInventoryFlags struct {
+	output        string
+	ageIdentities string
+}
+
+var driftInventoryArgs driftInventoryFlags
+
+// driftExitCode is returned when the cluster state differs from the inventory.
+const driftExitCode = 2
+
+func init() {
+	driftInventoryCmd.Flags().StringVarP(&driftInventoryArgs.output, "output", "o", "",
+		"The format in which the drift report should be printed, can be 'json'.")
+	driftInventoryCmd.Flags().StringVar(&driftInventoryArgs.ageIdentities, "age-identities", "",
+		"Path to a file containing one or more age identities (private keys generated by age-keygen).")
+
+	driftCmd.AddCommand(driftInventoryCmd)
+}
+
+// driftReport is the result of comparing an inventory with the cluster state.
+type driftReport struct {
+	Inventory string   `json:"inventory"`
+	Namespace string   `json:"namespace"`
+	Revision  int64    `json:"revision"`
+	Artifacts []string `json:"artifacts"`
+	Drifted   []string `json:"drifted"`
+	Missing   []string `json:"missing"`
+	Extra     []string `json:"extra"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// HasDrift returns true if any object was modified, deleted or added out-of-band.
+func (r *driftReport) HasDrift() bool {
+	return len(r.Drifted) > 0 || len(r.Missing) > 0 || len(r.Extra) > 0
+}
+
+func runDriftInventoryCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("you must specify an inventory name")
+	}
+	name := args[0]
+
+	if o := driftInventoryArgs.output; o != "" && o != "json" {
+		return fmt.Errorf("unsupported output format '%s', can be 'json'", o)
+	}
+
+	identities, err := registry.ParseAgeIdentities(driftInventoryArgs.ageIdentities)
+	if err != nil {
+		return fmt.Errorf("faild to read decryption keys: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	resMgr, err := newManager()
+	if err != nil {
+		return err
+	}
+
+	invStorage, err := newInventoryStorage(resMgr)
+	if err != nil {
+		return err
+	}
+
+	logger.Println("retrieving inventory...")
+	i := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
+	if err := invStorage.GetInventory(ctx, i); err != nil {
+		return err
+	}
+
+	if len(i.Artifacts) == 0 {
+		return fmt.Errorf("inventory %s/%s has no artifacts, only inventories applied from OCI artifacts can be checked for drift",
+			i.Namespace, i.Name)
+	}
+
+	var artifacts []string
+	for _, digest := range i.Artifacts {
+		artifacts = append(artifacts, registry.URLPrefix+digest)
+	}
+
+	logger.Println(fmt.Sprintf("building inventory from revision %d...", i.Generation))
+	objects, _, err := buildManifests(ctx, "", nil, artifacts, nil, identities)
+	if err != nil {
+		return err
+	}
+
+	// hooks run once per apply or delete and are not part of the inventory objects,
+	// the pre-delete hooks are recorded separately, so they are excluded from the drift detection
+	objects, _, err = splitHooks(objects)
+	if err != nil {
+		return err
+	}
+
+	builtInventory := inventory.NewInventory(name, i.Namespace)
+	if err := builtInventory.AddObjects(objects); err != nil {
+		return fmt.Errorf("creating inventory failed, error: %w", err)
+	}
+
+	diff, err := i.Diff(builtInventory)
+	if err != nil {
+		return err
+	}
+	if len(diff) > 0 {
+		return fmt.Errorf("the artifacts of inventory %s/%s do not contain %s, the inventory was applied from local manifests or patches",
+			i.Namespace, i.Name, ssa.FmtUnstructured(diff[0]))
+	}
+
+	report := &driftReport{
+		Inventory: i.Name,
+		Namespace: i.Namespace,
+		Revision:  i.Generation,
+		Artifacts: i.Artifacts,
+		Drifted:   []string{},
+		Missing:   []string{},
+		Extra:     []string{},
+	}
+
+	sort.Sort(ssa.SortableUnstructureds(objects))
+	resMgr.SetOwnerLabels(objects, i.Name, i.Namespace)
+
+	logger.Println(fmt.Sprintf("comparing %v object(s) with the cluster state...", len(objects)))
+	for _, object := range objects {
+		fixReplicasConflict(object, objects)
+
+		change, _, _, err := driftObject(ctx, resMgr, object)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+
+		switch change.Action {
+		case string(ssa.CreatedAction):
+			report.Missing = append(report.Missing, change.Subject)
+		case string(ssa.ConfiguredAction):
+			report.Drifted = append(report.Drifted, change.Subject)
+		}
+	}
+
+	extra, err := listExtraOwnedObjects(ctx, resMgr, i)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	for _, object := range extra {
+		report.Extra = append(report.Extra, ssa.FmtUnstructured(object))
+	}
+
+	if driftInventoryArgs.output == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		rootCmd.Println(string(data))
+	} else {
+		for _, subject := range report.Drifted {
+			rootCmd.Println(`►`, subject, "drifted")
+		}
+		for _, subject := range report.Missing {
+			rootCmd.Println(`►`, subject, "missing")
+		}
+		for _, subject := range report.Extra {
+			rootCmd.Println(`►`, subject, "extra")
+		}
+		for _, e := range report.Errors {
+			logger.Println(`✗`, e)
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("drift detection failed for inventory %s/%s", i.Namespace, i.Name)
+	}
+
+	if report.HasDrift() {
+		return &exitError{
+			code: driftExitCode,
+			err: fmt.Errorf("drift detected in inventory %s/%s: %v drifted, %v missing, %v extra",
+				i.Namespace, i.Name, len(report.Drifted), len(report.Missing), len(report.Extra)),
+		}
+	}
+
+	logger.Println(fmt.Sprintf("inventory %s/%s is in sync", i.Namespace, i.Name))
+	return nil
+}
+
+// listExtraOwnedObjects returns the objects labeled as owned by the inventory that are not recorded in it.
+func listExtraOwnedObjects(ctx context.Context, resMgr *ssa.ResourceManager, i *inventory.Inventory) ([]*unstructured.Unstructured, error) {
+	resources, err := discoverAPIResources(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	selector := labels.SelectorFromSet(resMgr.GetOwnerLabels(i.Name, i.Namespace))
+	objects, err := listAdoptableObjects(ctx, resMgr, resources, "", selector, i.Name, i.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	metas, err := i.ListMeta()
+	if err != nil {
+		return nil, err
+	}
+
+	var extra []*unstructured.Unstructured
+	for _, obj := range objects {
+		if !metas.Contains(object.UnstructuredToObjMetadata(obj)) {
+			extra = append(extra, obj)
+		}
+	}
+	return extra, nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDriftInventory(t *testing.T) {
+	g := NewWithT(t)
+	id := "drift-" + randStringRunes(5)
+	artifact := fmt.Sprintf("oci://%s/%s:v1.0.0", registryHost, id)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = executeCommand(fmt.Sprintf(
+		"push artifact %s -k %s",
+		artifact,
+		dir,
+	))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	output, err := executeCommand(fmt.Sprintf(
+		"apply inv %s -n %s -a %s",
+		id,
+		id,
+		artifact,
+	))
+	g.Expect(err).NotTo(HaveOccurred())
+	t.Logf("\n%s", output)
+
+	t.Run("reports no drift", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"drift inv %s -n %s",
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("in sync"))
+	})
+
+	t.Run("reports drifted and extra objects", func(t *testing.T) {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id,
+				Namespace: id,
+			},
+		}
+		err := envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		configMap.Data["key"] = "changed"
+		err = envTestClient.Update(context.Background(), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		extra := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id + "-extra",
+				Namespace: id,
+				Labels: map[string]string{
+					"inventory.kustomizer.dev/name":      id,
+					"inventory.kustomizer.dev/namespace": id,
+				},
+			},
+		}
+		err = envTestClient.Create(context.Background(), extra)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"drift inv %s -n %s -o json",
+			id,
+			id,
+		))
+		g.Expect(err).To(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		var exitErr *exitError
+		g.Expect(errors.As(err, &exitErr)).To(BeTrue())
+		g.Expect(exitErr.code).To(Equal(driftExitCode))
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf(`"drifted": [
+    "ConfigMap/%s/%s"`, id, id)))
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf(`"ConfigMap/%s/%s-extra"`, id, id)))
+	})
+}
@@ -0,0 +1,350 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var exportInventoryCmd = &cobra.Command{
+	Use:     "inventory",
+	Aliases: []string{"inv"},
+	Short:   "Export writes the given inventory and optionally the live state of its objects to a directory or tarball.",
+	Long: `The export command writes the inventory metadata, the list of objects and the OCI artifact digests to 'inventory.json'.
+When '--include-objects' is set, the live objects are written to 'objects.yaml',
+with the status, the managed fields and the server-generated metadata removed, along with the fields
+bound to the source cluster such as the Service IPs and node ports, the PVC volume bindings and the Job selectors.
+If the output path ends with '.tar.gz' or '.tgz', the files are written to a gzip compressed tarball.
+The export can be applied to another cluster or namespace with 'kustomizer restore inventory'.`,
+	Example: `  kustomizer export inventory <name> -n <namespace> -o <path> [--include-objects]
+
+  # Export an inventory applied from OCI artifacts to a directory
+  kustomizer export inventory my-app -n apps -o ./backup/my-app
+
+  # Export an inventory and the live state of its objects to a tarball
+  kustomizer export inventory my-app -n apps -o ./backup/my-app.tar.gz --include-objects
+`,
+	RunE: runExportInventoryCmd,
+}
+
+type exportInventoryFlags struct {
+	output         string
+	includeObjects bool
+}
+
+var exportInventoryArgs exportInventoryFlags
+
+const (
+	exportInventoryFile = "inventory.json"
+	exportObjectsFile   = "objects.yaml"
+)
+
+func init() {
+	exportInventoryCmd.Flags().StringVarP(&exportInventoryArgs.output, "output", "o", "",
+		"Path to the directory or the tarball ('.tar.gz' or '.tgz') where the inventory is exported.")
+	exportInventoryCmd.Flags().BoolVar(&exportInventoryArgs.includeObjects, "include-objects", false,
+		"Export the live state of the objects referenced by the inventory.")
+
+	exportCmd.AddCommand(exportInventoryCmd)
+}
+
+func runExportInventoryCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("you must specify an inventory name")
+	}
+	name := args[0]
+
+	if exportInventoryArgs.output == "" {
+		return fmt.Errorf("--output is required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	resMgr, err := newManager()
+	if err != nil {
+		return err
+	}
+
+	invStorage, err := newInventoryStorage(resMgr)
+	if err != nil {
+		return err
+	}
+
+	i := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
+	if err := invStorage.GetInventory(ctx, i); err != nil {
+		return err
+	}
+
+	files := make(map[string][]byte)
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return err
+	}
+	files[exportInventoryFile] = data
+
+	if exportInventoryArgs.includeObjects {
+		logger.Println(fmt.Sprintf("exporting %v object(s)...", len(i.Resources)))
+		objects, err := getLiveObjects(ctx, resMgr.Client(), i)
+		if err != nil {
+			return err
+		}
+
+		yml, err := ssa.ObjectsToYAML(objects)
+		if err != nil {
+			return err
+		}
+		files[exportObjectsFile] = []byte(yml)
+	}
+
+	if err := writeExport(exportInventoryArgs.output, files); err != nil {
+		return fmt.Errorf("writing %s failed: %w", exportInventoryArgs.output, err)
+	}
+
+	logger.Println(fmt.Sprintf("inventory %s/%s exported to %s", i.Namespace, i.Name, exportInventoryArgs.output))
+	return nil
+}
+
+// getLiveObjects retrieves the objects referenced by the inventory and
+// removes the fields set by the API server. Objects not found in the cluster are skipped.
+func getLiveObjects(ctx context.Context, kubeClient client.Client, i *inventory.Inventory) ([]*unstructured.Unstructured, error) {
+	refs, err := i.ListObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, ref := range refs {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(ref.GroupVersionKind())
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(ref), obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				logger.Println(`✗`, ssa.FmtUnstructured(ref), "skipped, not found in cluster")
+				continue
+			}
+			return nil, fmt.Errorf("%s query failed: %w", ssa.FmtUnstructured(ref), err)
+		}
+
+		stripServerFields(obj)
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// stripServerFields removes the status and the metadata fields populated by the API server,
+// so that the object can be applied to another cluster.
+func stripServerFields(obj *unstructured.Unstructured) {
+	unstructured.RemoveNestedField(obj.Object, "status")
+	for _, field := range []string{
+		"managedFields",
+		"uid",
+		"resourceVersion",
+		"generation",
+		"creationTimestamp",
+		"deletionTimestamp",
+		"deletionGracePeriodSeconds",
+		"selfLink",
+		"ownerReferences",
+	} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+
+	for _, field := range serverFields[obj.GroupVersionKind().GroupKind()] {
+		unstructured.RemoveNestedField(obj.Object, field...)
+	}
+
+	annotations := obj.GetAnnotations()
+	delete(annotations, corev1.LastAppliedConfigAnnotation)
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	obj.SetAnnotations(annotations)
+
+	if obj.GroupVersionKind().GroupKind() == (schema.GroupKind{Kind: "Service"}) {
+		// the cluster IPs are allocated by the API server, except for headless services
+		if clusterIP, _, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP"); clusterIP != corev1.ClusterIPNone {
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+		}
+
+		// the node ports are allocated by the API server for the NodePort and LoadBalancer services
+		ports, found, _ := unstructured.NestedSlice(obj.Object, "spec", "ports")
+		if found {
+			for _, port := range ports {
+				if p, ok := port.(map[string]interface{}); ok {
+					delete(p, "nodePort")
+				}
+			}
+			_ = unstructured.SetNestedSlice(obj.Object, ports, "spec", "ports")
+		}
+	}
+}
+
+// serverFields holds the fields populated by the API server or by the controllers of each kind,
+// these are bound to the cluster the object was exported from and would be rejected or conflict on restore.
+var serverFields = map[schema.GroupKind][][]string{
+	{Kind: "Service"}: {
+		{"spec", "healthCheckNodePort"},
+	},
+	{Kind: "PersistentVolumeClaim"}: {
+		{"spec", "volumeName"},
+		{"metadata", "annotations", "pv.kubernetes.io/bind-completed"},
+		{"metadata", "annotations", "pv.kubernetes.io/bound-by-controller"},
+		{"metadata", "annotations", "volume.beta.kubernetes.io/storage-provisioner"},
+		{"metadata", "annotations", "volume.kubernetes.io/storage-provisioner"},
+		{"metadata", "annotations", "volume.kubernetes.io/selected-node"},
+	},
+	{Group: "batch", Kind: "Job"}: {
+		{"spec", "selector"},
+		{"metadata", "labels", "controller-uid"},
+		{"metadata", "labels", "batch.kubernetes.io/controller-uid"},
+		{"metadata", "labels", "job-name"},
+		{"metadata", "labels", "batch.kubernetes.io/job-name"},
+		{"spec", "template", "metadata", "labels", "controller-uid"},
+		{"spec", "template", "metadata", "labels", "batch.kubernetes.io/controller-uid"},
+		{"spec", "template", "metadata", "labels", "job-name"},
+		{"spec", "template", "metadata", "labels", "batch.kubernetes.io/job-name"},
+	},
+	{Group: "apps", Kind: "Deployment"}: {
+		{"metadata", "annotations", "deployment.kubernetes.io/revision"},
+	},
+}
+
+func isTarball(path string) bool {
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+// writeExport writes the files to the given directory, or to a gzip compressed tarball.
+func writeExport(path string, files map[string][]byte) error {
+	if !isTarball(path) {
+		if err := os.MkdirAll(path, os.ModePerm); err != nil {
+			return err
+		}
+		for name, data := range files {
+			if err := os.WriteFile(filepath.Join(path, name), data, 0600); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	tarFile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer tarFile.Close()
+
+	gw := gzip.NewWriter(tarFile)
+	tw := tar.NewWriter(gw)
+	for _, name := range []string{exportInventoryFile, exportObjectsFile} {
+		data, ok := files[name]
+		if !ok {
+			continue
+		}
+
+		header := &tar.Header{
+			Name: name,
+			Mode: 0600,
+			Size: int64(len(data)),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// readExport reads the files from the given directory or gzip compressed tarball.
+func readExport(path string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
+	if !isTarball(path) {
+		for _, name := range []string{exportInventoryFile, exportObjectsFile} {
+			data, err := os.ReadFile(filepath.Join(path, name))
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			files[name] = data
+		}
+		return files, nil
+	}
+
+	tarFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer tarFile.Close()
+
+	gr, err := gzip.NewReader(tarFile)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		switch {
+		case err == io.EOF:
+			return files, nil
+		case err != nil:
+			return nil, err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[filepath.Base(header.Name)] = data
+	}
+}
@@ -0,0 +1,337 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fluxcd/pkg/ssa"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestExportRestoreInventory(t *testing.T) {
+	g := NewWithT(t)
+	id := "export-" + randStringRunes(5)
+	targetID := "restore-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = createNamespace(targetID)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	output, err := executeCommand(fmt.Sprintf(
+		"apply inv %s -k %s -n %s",
+		id,
+		dir,
+		id,
+	))
+	g.Expect(err).NotTo(HaveOccurred())
+	t.Logf("\n%s", output)
+
+	tarball := filepath.Join(t.TempDir(), id+".tar.gz")
+
+	t.Run("exports inventory with objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"export inv %s -n %s -o %s --include-objects",
+			id,
+			id,
+			tarball,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		files, err := readExport(tarball)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(files).To(HaveKey(exportInventoryFile))
+		g.Expect(string(files[exportObjectsFile])).To(ContainSubstring(fmt.Sprintf("name: %s", id)))
+		g.Expect(string(files[exportObjectsFile])).NotTo(ContainSubstring("managedFields"))
+		g.Expect(string(files[exportObjectsFile])).NotTo(ContainSubstring("resourceVersion"))
+	})
+
+	t.Run("restores inventory to another namespace", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"restore inv %s -n %s --from %s --target-namespace %s",
+			targetID,
+			targetID,
+			tarball,
+			targetID,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id,
+				Namespace: targetID,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(configMap.GetLabels()).To(HaveKeyWithValue("inventory.kustomizer.dev/name", targetID))
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inspect inv %s -n %s",
+			targetID,
+			targetID,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s", targetID, id)))
+	})
+}
+
+func TestStripServerFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		object string
+		want   string
+	}{
+		{
+			name: "service",
+			object: `
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+  uid: 3c1a0d1e
+spec:
+  type: NodePort
+  clusterIP: 10.96.0.10
+  clusterIPs:
+  - 10.96.0.10
+  ports:
+  - port: 80
+    nodePort: 30080
+status:
+  loadBalancer: {}
+`,
+			want: `
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+spec:
+  type: NodePort
+  ports:
+  - port: 80
+`,
+		},
+		{
+			name: "headless service",
+			object: `
+apiVersion: v1
+kind: Service
+metadata:
+  name: db
+spec:
+  clusterIP: None
+  clusterIPs:
+  - None
+  ports:
+  - port: 5432
+`,
+			want: `
+apiVersion: v1
+kind: Service
+metadata:
+  name: db
+spec:
+  clusterIP: None
+  clusterIPs:
+  - None
+  ports:
+  - port: 5432
+`,
+		},
+		{
+			name: "persistent volume claim",
+			object: `
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+  annotations:
+    pv.kubernetes.io/bind-completed: "yes"
+    volume.kubernetes.io/storage-provisioner: ebs.csi.aws.com
+spec:
+  accessModes:
+  - ReadWriteOnce
+  volumeName: pvc-3c1a0d1e
+`,
+			want: `
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+spec:
+  accessModes:
+  - ReadWriteOnce
+`,
+		},
+		{
+			name: "job",
+			object: `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  labels:
+    app: migrate
+    controller-uid: 3c1a0d1e
+    job-name: migrate
+spec:
+  selector:
+    matchLabels:
+      controller-uid: 3c1a0d1e
+  template:
+    metadata:
+      labels:
+        app: migrate
+        controller-uid: 3c1a0d1e
+        job-name: migrate
+    spec:
+      restartPolicy: Never
+`,
+			want: `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  labels:
+    app: migrate
+spec:
+  template:
+    metadata:
+      labels:
+        app: migrate
+    spec:
+      restartPolicy: Never
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			object, err := ssa.ReadObject(strings.NewReader(tt.object))
+			g.Expect(err).NotTo(HaveOccurred())
+			want, err := ssa.ReadObject(strings.NewReader(tt.want))
+			g.Expect(err).NotTo(HaveOccurred())
+
+			stripServerFields(object)
+			g.Expect(object.Object).To(Equal(want.Object))
+		})
+	}
+}
+
+func TestRestoreInventoryHooks(t *testing.T) {
+	g := NewWithT(t)
+	id := "export-hooks-" + randStringRunes(5)
+	targetID := "restore-hooks-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = createNamespace(targetID)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, []TestFile{
+		{
+			Name: "hooks.yaml",
+			Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: %[1]s
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: cleanup
+  namespace: %[1]s
+  annotations:
+    kustomizer.dev/hook: pre-delete
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: cleanup
+        image: ghcr.io/stefanprodan/podinfo:v6.0.0
+`, id),
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = executeCommand(fmt.Sprintf(
+		"apply inv %s -f %s -n %s",
+		id,
+		dir,
+		id,
+	))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	exportDir := filepath.Join(t.TempDir(), id)
+	_, err = executeCommand(fmt.Sprintf(
+		"export inv %s -n %s -o %s --include-objects",
+		id,
+		id,
+		exportDir,
+	))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("restores the pre-delete hooks to the target namespace", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"restore inv %s -n %s --from %s --target-namespace %s",
+			targetID,
+			targetID,
+			exportDir,
+			targetID,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		resMgr, err := newManager()
+		g.Expect(err).NotTo(HaveOccurred())
+
+		invStorage, err := newInventoryStorage(resMgr)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		restored := inventory.NewInventory(targetID, targetID)
+		err = invStorage.GetInventory(context.Background(), restored)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(restored.DeleteHooks).To(HaveLen(1))
+		g.Expect(restored.DeleteHooks[0].GetName()).To(Equal("cleanup"))
+		g.Expect(restored.DeleteHooks[0].GetNamespace()).To(Equal(targetID))
+	})
+}
@@ -24,8 +24,6 @@ import (
 	"github.com/fluxcd/pkg/ssa"
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
-
-	"github.com/stefanprodan/kustomizer/pkg/inventory"
 )
 
 var getInventories = &cobra.Command{
@@ -74,9 +72,9 @@ func runGetInventoriesCmd(cmd *cobra.Command, args []string) error {
 
 	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
 
-	invStorage := &inventory.Storage{
-		Manager: resMgr,
-		Owner:   inventoryOwner,
+	invStorage, err := newInventoryStorage(resMgr)
+	if err != nil {
+		return err
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
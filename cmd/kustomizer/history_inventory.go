@@ -0,0 +1,95 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var historyInventoryCmd = &cobra.Command{
+	Use:     "inventory",
+	Aliases: []string{"inv"},
+	Short:   "History prints a table with the revisions of the given inventory.",
+	Example: ` kustomizer history inventory <name> -n <namespace>
+
+  # List the revisions of an inventory
+  kustomizer history inv my-app -n apps
+`,
+	RunE: runHistoryInventoryCmd,
+}
+
+func init() {
+	historyCmd.AddCommand(historyInventoryCmd)
+}
+
+func runHistoryInventoryCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("you must specify an inventory name")
+	}
+	name := args[0]
+
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage, err := newInventoryStorage(resMgr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	revisions, err := invStorage.GetInventoryHistory(ctx, inventory.NewInventory(name, *kubeconfigArgs.Namespace))
+	if err != nil {
+		return err
+	}
+
+	if len(revisions) == 0 {
+		return fmt.Errorf("no revisions found for inventory %s/%s", *kubeconfigArgs.Namespace, name)
+	}
+
+	var rows [][]string
+	for _, rev := range revisions {
+		rows = append(rows, []string{
+			fmt.Sprintf("%v", rev.Generation),
+			rev.LastAppliedAt,
+			rev.Source,
+			rev.Revision,
+			strings.Join(rev.Artifacts, " "),
+		})
+	}
+
+	printTable(rootCmd.OutOrStdout(), []string{"revision", "applied at", "source", "source revision", "artifacts"}, rows)
+
+	return nil
+}
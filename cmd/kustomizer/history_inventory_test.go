@@ -0,0 +1,82 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestHistoryInventory(t *testing.T) {
+	g := NewWithT(t)
+	id := "history-" + randStringRunes(5)
+
+	source := "https://github.com/stefanprodan/kustomizer.git"
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("creates revisions", func(t *testing.T) {
+		for _, revision := range []string{"v1.0.0", "v2.0.0"} {
+			output, err := executeCommand(fmt.Sprintf(
+				"apply inv %s -k %s --namespace %s --source %s --revision %s",
+				id,
+				dir,
+				id,
+				source,
+				revision,
+			))
+
+			g.Expect(err).NotTo(HaveOccurred())
+			t.Logf("\n%s", output)
+		}
+	})
+
+	t.Run("prints revisions", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"history inventory %s --namespace %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`1\s+\S+\s+\S+\s+v1.0.0`))
+		g.Expect(output).To(MatchRegexp(`2\s+\S+\s+\S+\s+v2.0.0`))
+	})
+
+	t.Run("deletes revisions", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"delete inventory %s --namespace %s",
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"history inventory %s --namespace %s",
+			id,
+			id,
+		))
+		g.Expect(err).To(HaveOccurred())
+	})
+}
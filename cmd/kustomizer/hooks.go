@@ -0,0 +1,286 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// hookAnnotation marks a Job as a hook to be run at the given phase,
+	// hooks are not applied with the other objects, and only the pre-delete hooks are recorded in the inventory.
+	hookAnnotation = "kustomizer.dev/hook"
+
+	// hookDeletePolicyAnnotation is a comma separated list of policies
+	// that determine when the hook Job is deleted, defaults to before-hook-creation.
+	hookDeletePolicyAnnotation = "kustomizer.dev/hook-delete-policy"
+
+	preApplyHook  = "pre-apply"
+	postApplyHook = "post-apply"
+	preDeleteHook = "pre-delete"
+
+	// beforeHookCreationPolicy deletes the Job left by a previous run before creating it.
+	beforeHookCreationPolicy = "before-hook-creation"
+	// hookSucceededPolicy deletes the Job after it completed successfully.
+	hookSucceededPolicy = "hook-succeeded"
+	// hookFailedPolicy deletes the Job after it failed.
+	hookFailedPolicy = "hook-failed"
+
+	hookPollInterval = 2 * time.Second
+)
+
+// splitHooks separates the hook Jobs from the objects to be applied.
+func splitHooks(objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+	var resources []*unstructured.Unstructured
+	var hooks []*unstructured.Unstructured
+	for _, object := range objects {
+		phase, ok := object.GetAnnotations()[hookAnnotation]
+		if !ok {
+			resources = append(resources, object)
+			continue
+		}
+
+		if object.GetKind() != "Job" || object.GroupVersionKind().Group != "batch" {
+			return nil, nil, fmt.Errorf("%s can't be used as a hook, only Jobs are supported", ssa.FmtUnstructured(object))
+		}
+
+		switch phase {
+		case preApplyHook, postApplyHook, preDeleteHook:
+		default:
+			return nil, nil, fmt.Errorf("%s has an invalid %s annotation '%s', can be %s, %s or %s",
+				ssa.FmtUnstructured(object), hookAnnotation, phase, preApplyHook, postApplyHook, preDeleteHook)
+		}
+
+		for _, policy := range getHookDeletePolicies(object) {
+			switch policy {
+			case beforeHookCreationPolicy, hookSucceededPolicy, hookFailedPolicy:
+			default:
+				return nil, nil, fmt.Errorf("%s has an invalid %s annotation '%s', can be %s, %s or %s",
+					ssa.FmtUnstructured(object), hookDeletePolicyAnnotation, policy,
+					beforeHookCreationPolicy, hookSucceededPolicy, hookFailedPolicy)
+			}
+		}
+
+		hooks = append(hooks, object)
+	}
+	return resources, hooks, nil
+}
+
+// filterHooks returns the hook Jobs of the given phase.
+func filterHooks(hooks []*unstructured.Unstructured, phase string) []*unstructured.Unstructured {
+	var jobs []*unstructured.Unstructured
+	for _, hook := range hooks {
+		if hook.GetAnnotations()[hookAnnotation] == phase {
+			jobs = append(jobs, hook)
+		}
+	}
+	return jobs
+}
+
+func getHookDeletePolicies(object *unstructured.Unstructured) []string {
+	value, ok := object.GetAnnotations()[hookDeletePolicyAnnotation]
+	if !ok {
+		return []string{beforeHookCreationPolicy}
+	}
+
+	var policies []string
+	for _, policy := range strings.Split(value, ",") {
+		if policy = strings.TrimSpace(policy); policy != "" {
+			policies = append(policies, policy)
+		}
+	}
+	return policies
+}
+
+func hasHookDeletePolicy(object *unstructured.Unstructured, policy string) bool {
+	for _, p := range getHookDeletePolicies(object) {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// runHooks runs the hook Jobs of the given phase one at a time, in alphabetical order,
+// and waits for each Job to complete. The logs of the Job pods are streamed to stderr.
+func runHooks(ctx context.Context, resMgr *ssa.ResourceManager, hooks []*unstructured.Unstructured, phase, namespace string,
+	report *changeReport) error {
+	jobs := filterHooks(hooks, phase)
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	kubeConfig, err := newKubeConfig(kubeconfigArgs)
+	if err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	sort.Sort(ssa.SortableUnstructureds(jobs))
+	waitOpts := ssa.DefaultWaitOptions()
+	waitOpts.Timeout = rootArgs.timeout
+
+	logger.Println(fmt.Sprintf("running %v %s hook(s)...", len(jobs), phase))
+	for _, job := range jobs {
+		if job.GetNamespace() == "" {
+			job.SetNamespace(namespace)
+		}
+
+		if hasHookDeletePolicy(job, beforeHookCreationPolicy) {
+			if _, err := resMgr.Delete(ctx, job, ssa.DefaultDeleteOptions()); err != nil {
+				return err
+			}
+			if err := resMgr.WaitForTermination([]*unstructured.Unstructured{job}, waitOpts); err != nil {
+				return fmt.Errorf("wating for termination failed, error: %w", err)
+			}
+		}
+
+		change, err := resMgr.Apply(ctx, job, ssa.DefaultApplyOptions())
+		if err != nil {
+			report.AddError(ssa.FmtUnstructured(job), err)
+			return err
+		}
+		report.AddChange(*change)
+
+		start := time.Now()
+		succeeded, err := waitForHook(ctx, resMgr.Client(), clientset, job)
+		if err != nil {
+			report.AddWait(fmt.Sprintf("%s hook", phase), 1, start, err)
+			report.AddError(ssa.FmtUnstructured(job), err)
+			return err
+		}
+		hookErr := fmt.Errorf("%s hook %s failed", phase, ssa.FmtUnstructured(job))
+		if succeeded {
+			hookErr = nil
+		}
+		report.AddWait(fmt.Sprintf("%s hook", phase), 1, start, hookErr)
+
+		if (succeeded && hasHookDeletePolicy(job, hookSucceededPolicy)) ||
+			(!succeeded && hasHookDeletePolicy(job, hookFailedPolicy)) {
+			change, err := resMgr.Delete(ctx, job, ssa.DefaultDeleteOptions())
+			if err != nil {
+				return err
+			}
+			logger.Println(change.String())
+		}
+
+		if !succeeded {
+			report.AddError(ssa.FmtUnstructured(job), hookErr)
+			return hookErr
+		}
+		logger.Println(fmt.Sprintf("%s hook %s completed", phase, ssa.FmtUnstructured(job)))
+	}
+
+	return nil
+}
+
+// waitForHook polls the Job status until it's complete or failed,
+// and streams the logs of the Job pods in the meantime.
+func waitForHook(ctx context.Context, kubeClient client.Client, clientset kubernetes.Interface, job *unstructured.Unstructured) (bool, error) {
+	logsCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	streaming := make(map[string]bool)
+
+	ticker := time.NewTicker(hookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		existingJob := &unstructured.Unstructured{}
+		existingJob.SetGroupVersionKind(job.GroupVersionKind())
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(job), existingJob); err != nil {
+			return false, fmt.Errorf("%s query failed, error: %w", ssa.FmtUnstructured(job), err)
+		}
+
+		pods, err := clientset.CoreV1().Pods(job.GetNamespace()).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("controller-uid=%s", existingJob.GetUID()),
+		})
+		if err == nil {
+			for _, pod := range pods.Items {
+				if streaming[pod.Name] || pod.Status.Phase == corev1.PodPending {
+					continue
+				}
+				streaming[pod.Name] = true
+				wg.Add(1)
+				go func(pod string) {
+					defer wg.Done()
+					streamHookLogs(logsCtx, clientset, job.GetNamespace(), pod)
+				}(pod.Name)
+			}
+		}
+
+		if done, succeeded := getJobResult(existingJob); done {
+			wg.Wait()
+			return succeeded, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, fmt.Errorf("timeout waiting for %s to complete", ssa.FmtUnstructured(job))
+		case <-ticker.C:
+		}
+	}
+}
+
+// getJobResult returns true if the Job has finished, along with the outcome.
+func getJobResult(job *unstructured.Unstructured) (bool, bool) {
+	conditions, _, _ := unstructured.NestedSlice(job.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["status"] != string(corev1.ConditionTrue) {
+			continue
+		}
+		switch condition["type"] {
+		case "Complete":
+			return true, true
+		case "Failed":
+			return true, false
+		}
+	}
+	return false, false
+}
+
+func streamHookLogs(ctx context.Context, clientset kubernetes.Interface, namespace, pod string) {
+	stream, err := clientset.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{Follow: true}).Stream(ctx)
+	if err != nil {
+		logger.Println(`✗`, fmt.Sprintf("Pod/%s/%s logs unavailable: %v", namespace, pod, err))
+		return
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		logger.Println(fmt.Sprintf("[%s] %s", pod, scanner.Text()))
+	}
+}
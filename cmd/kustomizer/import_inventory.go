@@ -0,0 +1,381 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var importInventoryCmd = &cobra.Command{
+	Use:     "inventory",
+	Aliases: []string{"inv"},
+	Short:   "Import creates an inventory from a cli-utils ResourceGroup or a Flux Kustomization.",
+	Long: `The import command reads the list of objects from a cli-utils ResourceGroup (kpt.dev) spec
+or from a Flux Kustomization (kustomize.toolkit.fluxcd.io) status, and records them in the given inventory.
+The imported objects are labeled with the inventory ownership labels, and the fields managed by the
+previous tool are transferred to Kustomizer's field manager.
+Before importing, make sure the previous tool no longer reconciles the objects,
+e.g. suspend the Flux Kustomization or delete it with pruning disabled.`,
+	Example: `  kustomizer import inventory <name> -n <namespace> --from <kind>/<namespace>/<name>
+
+  # Import the objects applied by kpt
+  kustomizer import inventory my-app -n apps --from ResourceGroup/apps/inventory-my-app
+
+  # Import the objects reconciled by a Flux Kustomization
+  kustomizer import inventory my-app -n apps --from Kustomization/flux-system/my-app
+`,
+	RunE: runImportInventoryCmd,
+}
+
+type importInventoryFlags struct {
+	from          string
+	fieldManagers []string
+	lockTimeout   time.Duration
+}
+
+var importInventoryArgs = newImportInventoryFlags()
+
+func newImportInventoryFlags() importInventoryFlags {
+	return importInventoryFlags{
+		lockTimeout: time.Minute,
+	}
+}
+
+const (
+	resourceGroupKind = "ResourceGroup"
+	kustomizationKind = "Kustomization"
+)
+
+var importSourceGroups = map[string]string{
+	resourceGroupKind: "kpt.dev",
+	kustomizationKind: "kustomize.toolkit.fluxcd.io",
+}
+
+var importFieldManagers = map[string][]string{
+	resourceGroupKind: {"kpt", "kubectl"},
+	kustomizationKind: {"kustomize-controller"},
+}
+
+func init() {
+	importInventoryCmd.Flags().StringVar(&importInventoryArgs.from, "from", "",
+		"The object to import from in the format '<kind>/<namespace>/<name>', where kind can be ResourceGroup or Kustomization.")
+	importInventoryCmd.Flags().StringSliceVar(&importInventoryArgs.fieldManagers, "field-managers", nil,
+		"The field managers to be replaced by Kustomizer's field manager, "+
+			"defaults to 'kpt,kubectl' for ResourceGroup and 'kustomize-controller' for Kustomization.")
+	importInventoryCmd.Flags().DurationVar(&importInventoryArgs.lockTimeout, "lock-timeout", importInventoryArgs.lockTimeout,
+		"The length of time to wait for the inventory lock held by another operation to be released.")
+
+	importCmd.AddCommand(importInventoryCmd)
+}
+
+func runImportInventoryCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("you must specify an inventory name")
+	}
+	name := args[0]
+	namespace := *kubeconfigArgs.Namespace
+
+	parts := strings.Split(importInventoryArgs.from, "/")
+	if len(parts) != 3 || importSourceGroups[parts[0]] == "" {
+		return fmt.Errorf("--from must be in the format 'ResourceGroup/<namespace>/<name>' or 'Kustomization/<namespace>/<name>'")
+	}
+	sourceKind, sourceNamespace, sourceName := parts[0], parts[1], parts[2]
+
+	fieldManagers := importInventoryArgs.fieldManagers
+	if len(fieldManagers) == 0 {
+		fieldManagers = importFieldManagers[sourceKind]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	resMgr, err := newManager()
+	if err != nil {
+		return err
+	}
+
+	invStorage, err := newInventoryStorage(resMgr)
+	if err != nil {
+		return err
+	}
+
+	logger.Println(fmt.Sprintf("reading %s...", importInventoryArgs.from))
+	source, err := getImportSource(ctx, resMgr, sourceKind, sourceNamespace, sourceName)
+	if err != nil {
+		return err
+	}
+
+	var metas []object.ObjMetadata
+	revision := ""
+	switch sourceKind {
+	case resourceGroupKind:
+		metas, err = readResourceGroupEntries(source)
+	case kustomizationKind:
+		metas, err = readKustomizationEntries(source)
+		revision, _, _ = unstructured.NestedString(source.Object, "status", "lastAppliedRevision")
+	}
+	if err != nil {
+		return fmt.Errorf("reading the inventory from %s failed: %w", importInventoryArgs.from, err)
+	}
+
+	newInventory := inventory.NewInventory(name, namespace)
+	lock, err := lockInventory(ctx, invStorage, newInventory, importInventoryArgs.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Release(context.Background())
+
+	if err := invStorage.GetInventory(ctx, newInventory); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	newInventory.Source = importInventoryArgs.from
+	newInventory.Revision = revision
+
+	logger.Println(fmt.Sprintf("importing %v object(s)...", len(metas)))
+	var objects []*unstructured.Unstructured
+	for _, meta := range metas {
+		obj, err := getImportedObject(ctx, resMgr, meta)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				logger.Println(`✗`, ssa.FmtObjMetadata(meta), "skipped, not found in cluster")
+				continue
+			}
+			return err
+		}
+
+		if err := transferFieldManagers(ctx, resMgr, obj, fieldManagers); err != nil {
+			return err
+		}
+
+		if err := setOwnerLabels(ctx, resMgr, obj, name, namespace); err != nil {
+			return err
+		}
+
+		logger.Println(ssa.FmtUnstructured(obj), "imported")
+		objects = append(objects, obj)
+	}
+
+	if err := newInventory.AddObjects(objects); err != nil {
+		return fmt.Errorf("creating inventory failed, error: %w", err)
+	}
+
+	if err := invStorage.ApplyInventory(ctx, newInventory, false); err != nil {
+		return fmt.Errorf("inventory apply failed, error: %w", err)
+	}
+
+	logger.Println(fmt.Sprintf("inventory %s/%s contains %v object(s)", namespace, name, len(newInventory.Resources)))
+	return nil
+}
+
+// getImportSource retrieves the ResourceGroup or Kustomization using the API version preferred by the cluster.
+func getImportSource(ctx context.Context, resMgr *ssa.ResourceManager, kind, namespace, name string) (*unstructured.Unstructured, error) {
+	mapping, err := resMgr.Client().RESTMapper().RESTMapping(schema.GroupKind{
+		Group: importSourceGroups[kind],
+		Kind:  kind,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s API not found in cluster: %w", kind, err)
+	}
+
+	source := &unstructured.Unstructured{}
+	source.SetGroupVersionKind(mapping.GroupVersionKind)
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	if err := resMgr.Client().Get(ctx, key, source); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// readResourceGroupEntries extracts the object references from the ResourceGroup spec.
+func readResourceGroupEntries(source *unstructured.Unstructured) ([]object.ObjMetadata, error) {
+	resources, _, err := unstructured.NestedSlice(source.Object, "spec", "resources")
+	if err != nil {
+		return nil, err
+	}
+
+	var metas []object.ObjMetadata
+	for _, resource := range resources {
+		entry, ok := resource.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %v", resource)
+		}
+		metas = append(metas, object.ObjMetadata{
+			Namespace: fmt.Sprintf("%v", valueOrEmpty(entry["namespace"])),
+			Name:      fmt.Sprintf("%v", valueOrEmpty(entry["name"])),
+			GroupKind: schema.GroupKind{
+				Group: fmt.Sprintf("%v", valueOrEmpty(entry["group"])),
+				Kind:  fmt.Sprintf("%v", valueOrEmpty(entry["kind"])),
+			},
+		})
+	}
+	return metas, nil
+}
+
+// readKustomizationEntries parses the object IDs from the Kustomization status inventory,
+// which are in the same format as inventory.Resource.
+func readKustomizationEntries(source *unstructured.Unstructured) ([]object.ObjMetadata, error) {
+	entries, found, err := unstructured.NestedSlice(source.Object, "status", "inventory", "entries")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("status.inventory not found")
+	}
+
+	var metas []object.ObjMetadata
+	for _, e := range entries {
+		entry, ok := e.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %v", e)
+		}
+		meta, err := object.ParseObjMetadata(fmt.Sprintf("%v", entry["id"]))
+		if err != nil {
+			return nil, err
+		}
+		metas = append(metas, meta)
+	}
+	return metas, nil
+}
+
+// getImportedObject retrieves the live object using the API version preferred by the cluster.
+func getImportedObject(ctx context.Context, resMgr *ssa.ResourceManager, meta object.ObjMetadata) (*unstructured.Unstructured, error) {
+	mapping, err := resMgr.Client().RESTMapper().RESTMapping(meta.GroupKind)
+	if err != nil {
+		return nil, fmt.Errorf("%s API not found in cluster: %w", ssa.FmtObjMetadata(meta), err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(mapping.GroupVersionKind)
+	key := types.NamespacedName{Namespace: meta.Namespace, Name: meta.Name}
+	if err := resMgr.Client().Get(ctx, key, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// transferFieldManagers replaces the given managers in the managed fields of the live object
+// with Kustomizer's field manager, so that a subsequent apply can remove the fields that are
+// no longer present in the manifests.
+func transferFieldManagers(ctx context.Context, resMgr *ssa.ResourceManager, obj *unstructured.Unstructured, managers []string) error {
+	var entries []metav1.ManagedFieldsEntry
+	var transferred []metav1.ManagedFieldsEntry
+	for _, entry := range obj.GetManagedFields() {
+		if containsString(managers, entry.Manager) {
+			transferred = append(transferred, entry)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(transferred) == 0 {
+		return nil
+	}
+
+	for _, entry := range transferred {
+		entry.Manager = inventoryOwner.Field
+		entry.Operation = metav1.ManagedFieldsOperationApply
+
+		merged := false
+		for i, existing := range entries {
+			if existing.Manager == entry.Manager && existing.Operation == entry.Operation &&
+				existing.APIVersion == entry.APIVersion && existing.Subresource == entry.Subresource {
+				fields, err := mergeManagedFields(existing.FieldsV1, entry.FieldsV1)
+				if err != nil {
+					return fmt.Errorf("%s merging managed fields failed: %w", ssa.FmtUnstructured(obj), err)
+				}
+				entries[i].FieldsV1 = fields
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			entries = append(entries, entry)
+		}
+	}
+
+	patch, err := json.Marshal([]map[string]interface{}{
+		{
+			"op":    "replace",
+			"path":  "/metadata/managedFields",
+			"value": entries,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := resMgr.Client().Patch(ctx, obj, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+		return fmt.Errorf("%s transferring managed fields failed: %w", ssa.FmtUnstructured(obj), err)
+	}
+	return nil
+}
+
+func mergeManagedFields(a, b *metav1.FieldsV1) (*metav1.FieldsV1, error) {
+	if a == nil {
+		return b, nil
+	}
+	if b == nil {
+		return a, nil
+	}
+
+	aSet, err := ssa.FieldsToSet(*a)
+	if err != nil {
+		return nil, err
+	}
+	bSet, err := ssa.FieldsToSet(*b)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := ssa.SetToFields(*aSet.Union(&bSet))
+	if err != nil {
+		return nil, err
+	}
+	return &fields, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func valueOrEmpty(v interface{}) interface{} {
+	if v == nil {
+		return ""
+	}
+	return v
+}
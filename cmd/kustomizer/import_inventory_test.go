@@ -0,0 +1,143 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestImportInventory(t *testing.T) {
+	g := NewWithT(t)
+	id := "import-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata": map[string]interface{}{
+			"name": "kustomizations.kustomize.toolkit.fluxcd.io",
+		},
+		"spec": map[string]interface{}{
+			"group": "kustomize.toolkit.fluxcd.io",
+			"scope": "Namespaced",
+			"names": map[string]interface{}{
+				"kind":     "Kustomization",
+				"listKind": "KustomizationList",
+				"plural":   "kustomizations",
+				"singular": "kustomization",
+			},
+			"versions": []interface{}{
+				map[string]interface{}{
+					"name":    "v1beta2",
+					"served":  true,
+					"storage": true,
+					"schema": map[string]interface{}{
+						"openAPIV3Schema": map[string]interface{}{
+							"type":                                 "object",
+							"x-kubernetes-preserve-unknown-fields": true,
+						},
+					},
+				},
+			},
+		},
+	}}
+	err = envTestClient.Create(context.Background(), crd)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	managed := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      id + "-managed",
+			Namespace: id,
+		},
+		Data: map[string]string{"key": "value"},
+	}
+	err = envTestClient.Patch(context.Background(), managed, client.Apply,
+		client.FieldOwner("kustomize-controller"), client.ForceOwnership)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	ks := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "kustomize.toolkit.fluxcd.io/v1beta2",
+		"kind":       "Kustomization",
+		"metadata": map[string]interface{}{
+			"name":      id,
+			"namespace": id,
+		},
+		"status": map[string]interface{}{
+			"lastAppliedRevision": "main/6f7e3bd",
+			"inventory": map[string]interface{}{
+				"entries": []interface{}{
+					map[string]interface{}{"id": fmt.Sprintf("%s_%s-managed__ConfigMap", id, id), "v": "v1"},
+					map[string]interface{}{"id": fmt.Sprintf("%s_%s-missing__ConfigMap", id, id), "v": "v1"},
+				},
+			},
+		},
+	}}
+	g.Eventually(func() error {
+		return envTestClient.Create(context.Background(), ks)
+	}, 10*time.Second, time.Second).Should(Succeed())
+
+	t.Run("imports objects from Kustomization", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"import inv %s -n %s --from Kustomization/%s/%s",
+			id,
+			id,
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s-missing skipped", id, id)))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(managed), managed)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(managed.GetLabels()).To(HaveKeyWithValue("inventory.kustomizer.dev/name", id))
+		g.Expect(managed.Data).To(HaveKeyWithValue("key", "value"))
+
+		var managers []string
+		for _, entry := range managed.GetManagedFields() {
+			managers = append(managers, entry.Manager)
+		}
+		g.Expect(managers).To(ContainElement(inventoryOwner.Field))
+		g.Expect(managers).NotTo(ContainElement("kustomize-controller"))
+
+		output, err = executeCommand(fmt.Sprintf(
+			"inspect inv %s -n %s",
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("ConfigMap/%s/%s-managed", id, id)))
+		g.Expect(output).To(MatchRegexp("main/6f7e3bd"))
+	})
+}
@@ -129,6 +129,7 @@ func runInspectArtifactCmd(cmd *cobra.Command, args []string) error {
 		rootCmd.Println("VerifiedBy: cosign")
 	}
 	rootCmd.Println("CreatedAt:", meta.Created)
+	rootCmd.Println("Layout:", meta.Layout)
 	if meta.Encrypted != "" {
 		rootCmd.Println("EncryptedWith:", meta.Encrypted)
 	}
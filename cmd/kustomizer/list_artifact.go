@@ -85,27 +85,11 @@ func runListArtifactCmd(cmd *cobra.Command, args []string) error {
 	var rows [][]string
 
 	if exp := listArtifactArgs.semverExp; exp != "" {
-		c, err := semver.NewConstraint(exp)
+		matchingVersions, err := filterSemverTags(tags, exp)
 		if err != nil {
-			return fmt.Errorf("semver '%s' parse error: %w", exp, err)
+			return err
 		}
 
-		var matchingVersions []*semver.Version
-		for _, t := range tags {
-			v, err := semver.NewVersion(t)
-			if err != nil {
-				continue
-			}
-
-			if c != nil && !c.Check(v) {
-				continue
-			}
-
-			matchingVersions = append(matchingVersions, v)
-		}
-
-		sort.Sort(sort.Reverse(semver.Collection(matchingVersions)))
-
 		for _, ver := range matchingVersions {
 			row := []string{ver.String(), fmt.Sprintf("%s:%s", url, ver.Original())}
 			rows = append(rows, row)
@@ -124,3 +108,28 @@ func runListArtifactCmd(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// filterSemverTags returns the tags matching the semver constraint, ordered from the newest to the oldest version.
+func filterSemverTags(tags []string, exp string) ([]*semver.Version, error) {
+	c, err := semver.NewConstraint(exp)
+	if err != nil {
+		return nil, fmt.Errorf("semver '%s' parse error: %w", exp, err)
+	}
+
+	var matchingVersions []*semver.Version
+	for _, t := range tags {
+		v, err := semver.NewVersion(t)
+		if err != nil {
+			continue
+		}
+
+		if !c.Check(v) {
+			continue
+		}
+
+		matchingVersions = append(matchingVersions, v)
+	}
+
+	sort.Sort(sort.Reverse(semver.Collection(matchingVersions)))
+	return matchingVersions, nil
+}
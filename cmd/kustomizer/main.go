@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -51,14 +52,24 @@ Distribute Kubernetes configuration as OCI artifacts to container registries:
 Build, customize and apply Kubernetes resources:
 
 - kustomizer build inventory <name> [-a <oci url>] [-f <dir path>] [-p <patch path>] -k <overlay path>
-- kustomizer apply inventory <name> -n <namespace> [-a] [-f] [-p] -k --prune --wait --force
+- kustomizer apply inventory <name> -n <namespace> [-a] [-f] [-p] -k --prune --wait --force [--dry-run=server]
 - kustomizer diff inventory <name> -n <namespace> [-a] [-f] [-p] -k
+- kustomizer reconcile inventory <name> -n <namespace> -a <oci url> [--semver <condition>] --interval <duration>
 
 Manage the applied Kubernetes resources:
 
+- kustomizer adopt inventory <name> --namespace <namespace> -l <selector> --kinds <kinds>
+- kustomizer import inventory <name> --namespace <namespace> --from <ResourceGroup|Kustomization>/<namespace>/<name>
 - kustomizer get inventories --namespace <namespace>
 - kustomizer inspect inventory <name> --namespace <namespace>
+- kustomizer status inventory <name> --namespace <namespace> [--watch]
+- kustomizer drift inventory <name> --namespace <namespace> [-o json]
+- kustomizer history inventory <name> --namespace <namespace>
+- kustomizer export inventory <name> --namespace <namespace> -o <path> [--include-objects]
+- kustomizer restore inventory [name] --namespace <namespace> --from <path>
+- kustomizer rollback inventory <name> --namespace <namespace> --to-revision <number>
 - kustomizer delete inventory <name> --namespace <namespace>
+- kustomizer unlock inventory <name> --namespace <namespace>
 `,
 }
 
@@ -98,10 +109,29 @@ func main() {
 	loadConfig()
 	if err := rootCmd.Execute(); err != nil {
 		logger.Println(`✗`, err)
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
 
+// exitError signals that the command finished with the given exit code
+// e.g. when drift is detected.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
 func loadConfig() {
 	if c, err := config.Read(""); err != nil {
 		logger.Println(`✗`, fmt.Errorf("loading the config failed, error: %w", err))
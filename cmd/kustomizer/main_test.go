@@ -198,16 +198,24 @@ func executeCommand(cmd string) (string, error) {
 }
 
 func resetCmdArgs() {
-	applyInventoryArgs = applyInventoryFlags{}
+	adoptInventoryArgs = newAdoptInventoryFlags()
+	applyInventoryArgs = newApplyInventoryFlags()
 	buildInventoryArgs = buildInventoryFlags{}
-	deleteInventoryArgs = deleteInventoryFlags{}
-	diffInventoryArgs = diffInventoryFlags{}
-	diffArtifactArgs = diffArtifactFlags{}
+	deleteInventoryArgs = newDeleteInventoryFlags()
+	diffInventoryArgs = newDiffInventoryFlags()
+	diffArtifactArgs = newDiffArtifactFlags()
+	driftInventoryArgs = driftInventoryFlags{}
+	exportInventoryArgs = exportInventoryFlags{}
 	getInventoriesArgs = getInventoriesFlags{}
+	importInventoryArgs = newImportInventoryFlags()
 	inspectArtifactArgs = inspectArtifactFlags{}
 	listArtifactArgs = listArtifactFlags{}
 	pullArtifactArgs = pullArtifactFlags{}
-	pushArtifactArgs = pushArtifactFlags{}
+	pushArtifactArgs = newPushArtifactFlags()
+	reconcileInventoryArgs = newReconcileInventoryFlags()
+	restoreInventoryArgs = newRestoreInventoryFlags()
+	rollbackInventoryArgs = newRollbackInventoryFlags()
+	statusInventoryArgs = newStatusInventoryFlags()
 }
 
 var testManifests = func(name, namespace string, immutable bool) []TestFile {
@@ -0,0 +1,214 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "kustomizer"
+
+// metricsRecorder holds the Prometheus metrics and the health state exposed by the long-running modes.
+type metricsRecorder struct {
+	registry *prometheus.Registry
+	ready    atomic.Bool
+
+	applyDuration *prometheus.HistogramVec
+	applyTotal    *prometheus.CounterVec
+	lastApplied   *prometheus.GaugeVec
+	objects       *prometheus.GaugeVec
+	drift         *prometheus.GaugeVec
+	pullDuration  *prometheus.HistogramVec
+	pullBytes     *prometheus.CounterVec
+}
+
+// metrics is recorded by all commands and served only by the long-running modes.
+var metrics = newMetricsRecorder()
+
+func newMetricsRecorder() *metricsRecorder {
+	m := &metricsRecorder{
+		registry: prometheus.NewRegistry(),
+		applyDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "apply_duration_seconds",
+			Help:      "The duration in seconds of the inventory apply operations.",
+			Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
+		}, []string{"name", "namespace", "success"}),
+		applyTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "apply_total",
+			Help:      "The total number of inventory apply operations by result.",
+		}, []string{"name", "namespace", "result"}),
+		lastApplied: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "last_successful_apply_timestamp_seconds",
+			Help:      "The Unix timestamp of the last successful inventory apply.",
+		}, []string{"name", "namespace"}),
+		objects: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "objects",
+			Help:      "The number of objects by action performed in the last inventory apply.",
+		}, []string{"name", "namespace", "action"}),
+		drift: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "drift_objects",
+			Help:      "The number of objects that drifted from the inventory at the last check.",
+		}, []string{"name", "namespace"}),
+		pullDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "oci_pull_duration_seconds",
+			Help:      "The duration in seconds of the OCI artifact pulls.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"repository", "success"}),
+		pullBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "oci_pull_bytes_total",
+			Help:      "The total size in bytes of the pulled OCI artifacts content.",
+		}, []string{"repository"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.applyDuration,
+		m.applyTotal,
+		m.lastApplied,
+		m.objects,
+		m.drift,
+		m.pullDuration,
+		m.pullBytes,
+	)
+	return m
+}
+
+// RecordApply records the duration and the result of an inventory apply,
+// and the number of objects for each action if a report is available.
+func (m *metricsRecorder) RecordApply(name, namespace string, start time.Time, report *changeReport, err error) {
+	success := err == nil
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+
+	m.applyDuration.WithLabelValues(name, namespace, boolLabel(success)).Observe(time.Since(start).Seconds())
+	m.applyTotal.WithLabelValues(name, namespace, result).Inc()
+
+	if report != nil {
+		for _, action := range []ssa.Action{ssa.CreatedAction, ssa.ConfiguredAction, ssa.UnchangedAction, ssa.DeletedAction} {
+			m.objects.WithLabelValues(name, namespace, string(action)).Set(float64(report.Count(string(action))))
+		}
+	}
+
+	if success {
+		m.lastApplied.WithLabelValues(name, namespace).SetToCurrentTime()
+	}
+}
+
+// RecordDrift records the number of drifted objects found in an inventory.
+func (m *metricsRecorder) RecordDrift(name, namespace string, count int) {
+	m.drift.WithLabelValues(name, namespace).Set(float64(count))
+}
+
+// RecordPull records the duration of an OCI artifact pull and the size of its content.
+func (m *metricsRecorder) RecordPull(repository string, start time.Time, size int, err error) {
+	m.pullDuration.WithLabelValues(repository, boolLabel(err == nil)).Observe(time.Since(start).Seconds())
+	if err == nil {
+		m.pullBytes.WithLabelValues(repository).Add(float64(size))
+	}
+}
+
+// SetReady marks the process as ready or not ready to be served by the readiness probe.
+func (m *metricsRecorder) SetReady(ready bool) {
+	m.ready.Store(ready)
+}
+
+// Handler returns the HTTP handler serving '/metrics', '/healthz' and '/readyz'.
+func (m *metricsRecorder) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		if !m.ready.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	})
+	return mux
+}
+
+// serveMetrics binds the metrics and health endpoints listener and serves it in the background,
+// the listener is shut down when the context is cancelled.
+func serveMetrics(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("metrics listener failed: %w", err)
+	}
+
+	srv := &http.Server{
+		Handler:           metrics.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		logger.Println("serving metrics on", listener.Addr())
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Println(`✗`, "metrics listener failed:", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	return nil
+}
+
+// repositoryOf returns the repository of the given artifact reference, without the tag or digest.
+func repositoryOf(url string) string {
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return url
+	}
+	return ref.Context().String()
+}
+
+func boolLabel(value bool) string {
+	if value {
+		return "true"
+	}
+	return "false"
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestMetrics(t *testing.T) {
+	g := NewWithT(t)
+	id := "metrics-" + randStringRunes(5)
+
+	m := newMetricsRecorder()
+	srv := httptest.NewServer(m.Handler())
+	defer srv.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(srv.URL + path)
+		g.Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		g.Expect(err).NotTo(HaveOccurred())
+		return resp.StatusCode, string(body)
+	}
+
+	t.Run("serves health endpoints", func(t *testing.T) {
+		code, _ := get("/healthz")
+		g.Expect(code).To(Equal(http.StatusOK))
+
+		code, _ = get("/readyz")
+		g.Expect(code).To(Equal(http.StatusServiceUnavailable))
+
+		m.SetReady(true)
+		code, _ = get("/readyz")
+		g.Expect(code).To(Equal(http.StatusOK))
+
+		m.SetReady(false)
+		code, _ = get("/readyz")
+		g.Expect(code).To(Equal(http.StatusServiceUnavailable))
+	})
+
+	t.Run("serves apply metrics", func(t *testing.T) {
+		report := &changeReport{
+			Changes: []reportChange{
+				{Subject: "ConfigMap/default/test", Action: string(ssa.CreatedAction)},
+			},
+			Pruned: []reportChange{
+				{Subject: "Secret/default/test", Action: string(ssa.DeletedAction)},
+			},
+		}
+		m.RecordApply(id, "default", time.Now(), report, nil)
+		m.RecordApply(id, "default", time.Now(), nil, fmt.Errorf("failed"))
+		m.RecordDrift(id, "default", 3)
+		m.RecordPull("localhost/repo", time.Now(), 1024, nil)
+
+		code, body := get("/metrics")
+		g.Expect(code).To(Equal(http.StatusOK))
+		t.Logf("\n%s", body)
+		g.Expect(body).To(ContainSubstring(fmt.Sprintf(`kustomizer_apply_total{name="%s",namespace="default",result="success"} 1`, id)))
+		g.Expect(body).To(ContainSubstring(fmt.Sprintf(`kustomizer_apply_total{name="%s",namespace="default",result="failure"} 1`, id)))
+		g.Expect(body).To(ContainSubstring(fmt.Sprintf(`kustomizer_objects{action="created",name="%s",namespace="default"} 1`, id)))
+		g.Expect(body).To(ContainSubstring(fmt.Sprintf(`kustomizer_objects{action="deleted",name="%s",namespace="default"} 1`, id)))
+		g.Expect(body).To(ContainSubstring(fmt.Sprintf(`kustomizer_drift_objects{name="%s",namespace="default"} 3`, id)))
+		g.Expect(body).To(ContainSubstring(fmt.Sprintf(`kustomizer_last_successful_apply_timestamp_seconds{name="%s",namespace="default"}`, id)))
+		g.Expect(body).To(ContainSubstring(`kustomizer_oci_pull_bytes_total{repository="localhost/repo"} 1024`))
+		g.Expect(body).To(ContainSubstring(`kustomizer_oci_pull_duration_seconds_count{repository="localhost/repo",success="true"} 1`))
+	})
+}
+
+func TestServeMetrics(t *testing.T) {
+	g := NewWithT(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("fails when the address is in use", func(t *testing.T) {
+		err := serveMetrics(ctx, listener.Addr().String())
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("metrics listener failed"))
+	})
+
+	t.Run("serves on a free address", func(t *testing.T) {
+		port, err := getFreePort()
+		g.Expect(err).NotTo(HaveOccurred())
+		addr := fmt.Sprintf("127.0.0.1:%d", port)
+
+		err = serveMetrics(ctx, addr)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		resp, err := http.Get(fmt.Sprintf("http://%s/healthz", addr))
+		g.Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	})
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/stefanprodan/kustomizer/pkg/notification"
+)
+
+// notificationTimeout limits the time spent posting a notification, including retries.
+const notificationTimeout = 2 * time.Minute
+
+// notify posts the outcome of an inventory operation to the webhooks configured in '~/.kustomizer/config'.
+// Notification failures are logged without affecting the result of the operation.
+func notify(operation string, report *changeReport, opErr error) {
+	notifier := notification.NewNotifier(cfg.Notifications)
+	if !notifier.Enabled() || report == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+
+	if err := notifier.Post(ctx, newNotificationEvent(operation, report, opErr)); err != nil {
+		logger.Println(`✗`, err)
+	}
+}
+
+func newNotificationEvent(operation string, report *changeReport, opErr error) notification.Event {
+	event := notification.Event{
+		Operation: operation,
+		Inventory: report.Inventory.Name,
+		Namespace: report.Inventory.Namespace,
+		Source:    report.Inventory.Source,
+		Revision:  report.Inventory.Revision,
+		Artifacts: report.Inventory.Artifacts,
+		Changes:   make(map[string]int),
+		Timestamp: time.Now().UTC(),
+	}
+
+	for _, change := range append(report.Changes, report.Pruned...) {
+		event.Changes[change.Action]++
+	}
+
+	if opErr != nil {
+		event.Error = opErr.Error()
+	}
+
+	return event
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/stefanprodan/kustomizer/pkg/config"
+	"github.com/stefanprodan/kustomizer/pkg/notification"
+)
+
+// webhookRecorder is a local HTTP server that records the notification payloads by path,
+// the first request sent to each path listed in failOnce is rejected to exercise the retries.
+type webhookRecorder struct {
+	mu       sync.Mutex
+	payloads map[string][][]byte
+	failOnce map[string]bool
+}
+
+func (r *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failOnce[req.URL.Path] {
+		r.failOnce[req.URL.Path] = false
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, _ := io.ReadAll(req.Body)
+	r.payloads[req.URL.Path] = append(r.payloads[req.URL.Path], body)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (r *webhookRecorder) Last(path string) []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	payloads := r.payloads[path]
+	if len(payloads) == 0 {
+		return nil
+	}
+	return payloads[len(payloads)-1]
+}
+
+func TestNotifications(t *testing.T) {
+	g := NewWithT(t)
+	id := "notify-" + randStringRunes(5)
+
+	recorder := &webhookRecorder{
+		payloads: make(map[string][][]byte),
+		failOnce: map[string]bool{"/generic": true},
+	}
+	srv := httptest.NewServer(recorder)
+	defer srv.Close()
+
+	retries := 1
+	defaultNotifications := cfg.Notifications
+	cfg.Notifications = &config.NotificationOptions{
+		Retries: &retries,
+		Webhooks: []config.Webhook{
+			{Type: config.WebhookGeneric, URL: srv.URL + "/generic"},
+			{Type: config.WebhookSlack, URL: srv.URL + "/slack"},
+			{Type: config.WebhookMSTeams, URL: srv.URL + "/msteams"},
+		},
+	}
+	defer func() {
+		cfg.Notifications = defaultNotifications
+	}()
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("notifies apply", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --source %s --revision %s",
+			id,
+			dir,
+			id,
+			"https://github.com/org/repo",
+			"v1.0.0",
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		var event notification.Event
+		err = json.Unmarshal(recorder.Last("/generic"), &event)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(event.Operation).To(Equal("apply"))
+		g.Expect(event.Inventory).To(Equal(id))
+		g.Expect(event.Namespace).To(Equal(id))
+		g.Expect(event.Source).To(Equal("https://github.com/org/repo"))
+		g.Expect(event.Revision).To(Equal("v1.0.0"))
+		g.Expect(event.Changes).To(HaveKeyWithValue("created", 3))
+		g.Expect(event.Error).To(BeEmpty())
+
+		slack := string(recorder.Last("/slack"))
+		g.Expect(slack).To(ContainSubstring(fmt.Sprintf(`"text":"apply %s/%s succeeded"`, id, id)))
+		g.Expect(slack).To(ContainSubstring(`"color":"good"`))
+		g.Expect(slack).To(ContainSubstring(`"value":"3 created"`))
+
+		teams := string(recorder.Last("/msteams"))
+		g.Expect(teams).To(ContainSubstring(`"@type":"MessageCard"`))
+		g.Expect(teams).To(ContainSubstring(fmt.Sprintf(`"activityTitle":"apply %s/%s succeeded"`, id, id)))
+		g.Expect(teams).To(ContainSubstring(`{"name":"Revision","value":"v1.0.0"}`))
+	})
+
+	t.Run("notifies delete", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"delete inv %s -n %s",
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		var event notification.Event
+		err = json.Unmarshal(recorder.Last("/generic"), &event)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(event.Operation).To(Equal("delete"))
+		g.Expect(event.Changes).To(HaveKeyWithValue("deleted", 3))
+	})
+
+	t.Run("notifies failures", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"delete inv %s -n %s",
+			id,
+			id,
+		))
+		g.Expect(err).To(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		g.Expect(string(recorder.Last("/slack"))).To(ContainSubstring(`"color":"danger"`))
+		g.Expect(string(recorder.Last("/msteams"))).To(ContainSubstring(`{"name":"Error","value":`))
+	})
+}
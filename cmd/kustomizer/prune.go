@@ -0,0 +1,133 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/kustomizer/pkg/config"
+)
+
+// prunePolicyAnnotation protects an object from being deleted by prune or delete operations,
+// can be set to 'disabled' or 'orphan'.
+const prunePolicyAnnotation = "kustomizer.dev/prune"
+
+// The actions recorded for the stale objects protected from pruning.
+const (
+	prunedSkippedAction  ssa.Action = "skipped"
+	prunedOrphanedAction ssa.Action = "orphaned"
+)
+
+// getPrunePolicy returns the prune policy of the in-cluster object,
+// an empty string means the object can be deleted.
+func getPrunePolicy(object *unstructured.Unstructured) string {
+	switch policy := object.GetAnnotations()[prunePolicyAnnotation]; policy {
+	case config.PruneDisabled, config.PruneOrphan:
+		return policy
+	}
+
+	if isProtectedKind(object) {
+		return cfg.Prune.Policy
+	}
+
+	return ""
+}
+
+// isProtectedKind returns true if the object kind is in the config protected kinds list,
+// the list entries are in the format '<kind>' or '<kind>.<group>'.
+func isProtectedKind(object *unstructured.Unstructured) bool {
+	gvk := object.GroupVersionKind()
+	for _, entry := range cfg.Prune.ProtectedKinds {
+		kind, group, hasGroup := strings.Cut(entry, ".")
+		if kind == gvk.Kind && (!hasGroup || group == gvk.Group) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterPrunableObjects returns the objects that can be deleted. The protected objects are skipped,
+// or orphaned by removing the ownership labels, according to their prune policy.
+// In dry-run mode, the orphaned objects are not modified.
+func filterPrunableObjects(ctx context.Context, resMgr *ssa.ResourceManager, objects []*unstructured.Unstructured,
+	dryRun bool, report *changeReport) ([]*unstructured.Unstructured, error) {
+	suffix := ""
+	if dryRun {
+		suffix = " (server dry run)"
+	}
+
+	var prunable []*unstructured.Unstructured
+	for _, object := range objects {
+		existingObject := &unstructured.Unstructured{}
+		existingObject.SetGroupVersionKind(object.GroupVersionKind())
+		if err := resMgr.Client().Get(ctx, client.ObjectKeyFromObject(object), existingObject); err != nil {
+			if apierrors.IsNotFound(err) {
+				prunable = append(prunable, object)
+				continue
+			}
+			return nil, fmt.Errorf("%s query failed, error: %w", ssa.FmtUnstructured(object), err)
+		}
+
+		switch getPrunePolicy(existingObject) {
+		case config.PruneDisabled:
+			logger.Println(ssa.FmtUnstructured(object), "skipped, prune disabled"+suffix)
+			report.RecordPruned(newChangeSetEntry(object, prunedSkippedAction))
+		case config.PruneOrphan:
+			if !dryRun {
+				if err := removeOwnerLabels(ctx, resMgr, existingObject); err != nil {
+					return nil, err
+				}
+			}
+			logger.Println(ssa.FmtUnstructured(object), "orphaned"+suffix)
+			report.RecordPruned(newChangeSetEntry(object, prunedOrphanedAction))
+		default:
+			prunable = append(prunable, object)
+		}
+	}
+	return prunable, nil
+}
+
+// removeOwnerLabels patches the in-cluster object to remove the inventory ownership labels.
+func removeOwnerLabels(ctx context.Context, resMgr *ssa.ResourceManager, object *unstructured.Unstructured) error {
+	ownerLabels := make(map[string]interface{})
+	for key := range resMgr.GetOwnerLabels("", "") {
+		ownerLabels[key] = nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": ownerLabels,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := resMgr.Client().Patch(ctx, object, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		return fmt.Errorf("%s removing the ownership labels failed: %w", ssa.FmtUnstructured(object), err)
+	}
+	return nil
+}
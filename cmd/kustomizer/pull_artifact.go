@@ -22,6 +22,7 @@ import (
 	"github.com/spf13/cobra"
 	"os"
 	"os/exec"
+	"path/filepath"
 
 	"github.com/stefanprodan/kustomizer/pkg/registry"
 )
@@ -29,7 +30,8 @@ import (
 var pullArtifactCmd = &cobra.Command{
 	Use:   "artifact",
 	Short: "Pull downloads Kubernetes manifests from a container registry.",
-	Long: `The pull command downloads the specified OCI artifact and writes the Kubernetes manifests to stdout.
+	Long: `The pull command downloads the specified OCI artifact and writes the Kubernetes manifests to stdout,
+or restores the artifact files to a directory with '--output-dir'.
 For private registries, the pull command uses the credentials from '~/.docker/config.json'.`,
 	Example: `  kustomizer pull artifact <oci url>
 
@@ -45,6 +47,9 @@ For private registries, the pull command uses the credentials from '~/.docker/co
   # Pull and verify artifact with cosign
   kustomizer pull artifact oci://docker.io/user/repo:v1.0.0 --verify --cosign-key ./keys/cosign.pub
 
+  # Pull an artifact and restore its files to a directory
+  kustomizer pull artifact oci://docker.io/user/repo:v1.0.0 --output-dir ./deploy
+
   # Pull encrypted artifact
   kustomizer pull artifact oci://docker.io/user/repo:v1.0.0 --age-identities ./keys/id.txt
 `,
@@ -55,6 +60,7 @@ type pullArtifactFlags struct {
 	ageIdentities string
 	verify        bool
 	verifyKey     string
+	outputDir     string
 }
 
 var pullArtifactArgs pullArtifactFlags
@@ -67,6 +73,8 @@ func init() {
 	pullArtifactCmd.Flags().StringVar(&pullArtifactArgs.verifyKey, "cosign-key", "",
 		"Path to the consign public key file, KMS URI or Kubernetes Secret. "+
 			"When not specified, cosign will try to verify the signature using Rekor.")
+	pullArtifactCmd.Flags().StringVarP(&pullArtifactArgs.outputDir, "output-dir", "o", "",
+		"Write the artifact files to the given directory instead of printing the manifests to stdout.")
 
 	pullCmd.AddCommand(pullArtifactCmd)
 }
@@ -95,6 +103,26 @@ func runPullArtifactCmd(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
 	defer cancel()
 
+	if pullArtifactArgs.outputDir != "" {
+		files, _, err := registry.PullFiles(ctx, url, identities)
+		if err != nil {
+			return fmt.Errorf("pulling %s failed: %w", url, err)
+		}
+
+		for _, file := range files {
+			filePath := filepath.Join(pullArtifactArgs.outputDir, filepath.FromSlash(file.Path))
+			if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+				return err
+			}
+			if err := os.WriteFile(filePath, file.Data, 0600); err != nil {
+				return err
+			}
+		}
+
+		logger.Println(fmt.Sprintf("%v file(s) written to %s", len(files), pullArtifactArgs.outputDir))
+		return nil
+	}
+
 	yml, _, err := registry.Pull(ctx, url, identities)
 	if err != nil {
 		return fmt.Errorf("pulling %s failed: %w", url, err)
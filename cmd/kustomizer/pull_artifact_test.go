@@ -18,6 +18,7 @@ package main
 
 import (
 	"fmt"
+	"path/filepath"
 	"testing"
 
 	. "github.com/onsi/gomega"
@@ -57,4 +58,42 @@ func TestPull(t *testing.T) {
 		t.Logf("\n%s", output)
 		g.Expect(output).To(MatchRegexp(id))
 	})
+
+	t.Run("pull artifact tree", func(t *testing.T) {
+		treeArtifact := fmt.Sprintf("oci://%s/%s:%s", registryHost, id, "tree")
+		_, err := executeCommand(fmt.Sprintf(
+			"push artifact %s -k %s --layout=tree",
+			treeArtifact,
+			dir,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"inspect artifact %s",
+			treeArtifact,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("Layout: tree"))
+
+		outputDir := filepath.Join(tmpDir, id+"-pull")
+		output, err = executeCommand(fmt.Sprintf(
+			"pull artifact %s --output-dir %s",
+			treeArtifact,
+			outputDir,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(filepath.Join(outputDir, "kustomization.yaml")).To(BeAnExistingFile())
+		g.Expect(filepath.Join(outputDir, "namespaces", id, fmt.Sprintf("configmap-%s.yaml", id))).To(BeAnExistingFile())
+
+		output, err = executeCommand(fmt.Sprintf(
+			"build inventory %s -k %s -o yaml",
+			id,
+			outputDir,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf("name: %s", id)))
+	})
 }
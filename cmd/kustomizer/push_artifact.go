@@ -19,15 +19,19 @@ package main
 import (
 	"bufio"
 	"context"
-	"crypto/sha256"
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/fluxcd/pkg/ssa"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
 
 	"github.com/stefanprodan/kustomizer/pkg/registry"
 )
@@ -38,6 +42,10 @@ var pushArtifactCmd = &cobra.Command{
 	Long: `The push command scans the given path for Kubernetes manifests or Kustomize overlays,
 builds the manifests into a multi-doc YAML, packages the YAML file into an OCI artifact and
 pushes the image to the container registry.
+With '--layout=tree', the artifact contains a file per object, in a directory per namespace,
+and a kustomization.yaml listing the files, which can be restored with 'pull artifact --output-dir'.
+The layout is recorded in the artifact metadata, note that kustomizer releases prior to the tree layout
+fail to pull tree artifacts with a checksum mismatch error.
 The push command uses the credentials from '~/.docker/config.json'.`,
 	Example: `  kustomizer push artifact <oci url> -k <overlay path> [-f <dir path>|<file path>]
 
@@ -63,6 +71,9 @@ The push command uses the credentials from '~/.docker/config.json'.`,
   # Push and sign artifact with cosign and GitHub OIDC (GH Actions)
   kustomizer push artifact oci://docker.io/user/repo:v1.0.0 -f ./deploy/manifests --sign
 
+  # Push an artifact with a file per object and a kustomization
+  kustomizer push artifact oci://docker.io/user/repo:v1.0.0 -k ./deploy/production --layout=tree
+
   # Push encrypted artifact
   kustomizer push artifact oci://docker.io/user/repo:v1.0.0 -f ./deploy/manifests --age-recipients ./keys/pub.txt 
 `,
@@ -78,9 +89,22 @@ type pushArtifactFlags struct {
 	signKey       string
 	source        string
 	revision      string
+	layout        string
+}
+
+func newPushArtifactFlags() pushArtifactFlags {
+	return pushArtifactFlags{
+		layout: fileLayout,
+	}
 }
 
-var pushArtifactArgs pushArtifactFlags
+var pushArtifactArgs = newPushArtifactFlags()
+
+// The layouts of the artifact content.
+const (
+	fileLayout = registry.FileLayout
+	treeLayout = registry.TreeLayout
+)
 
 func init() {
 	pushArtifactCmd.Flags().StringSliceVarP(&pushArtifactArgs.filename, "filename", "f", nil,
@@ -98,6 +122,10 @@ func init() {
 			"When not specified, cosign will try to producing an identity token from the environment (GH Actions or GCP).")
 	pushArtifactCmd.Flags().StringVar(&pushArtifactArgs.source, "source", "", "the source address, e.g. the Git URL")
 	pushArtifactCmd.Flags().StringVar(&pushArtifactArgs.revision, "revision", "", "the source revision in the format '<branch|tag>/<commit-sha>'")
+	pushArtifactCmd.Flags().StringVar(&pushArtifactArgs.layout, "layout", pushArtifactArgs.layout,
+		fmt.Sprintf("The layout of the artifact content, can be '%s' for a single multi-doc YAML or '%s' for a file per object and a kustomization. "+
+			"Tree artifacts can't be pulled by kustomizer releases prior to this flag.",
+			fileLayout, treeLayout))
 
 	pushCmd.AddCommand(pushArtifactCmd)
 }
@@ -111,6 +139,10 @@ func runPushArtifactCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("-f or -k is required")
 	}
 
+	if pushArtifactArgs.layout != fileLayout && pushArtifactArgs.layout != treeLayout {
+		return fmt.Errorf("unsupported layout '%s', can be '%s' or '%s'", pushArtifactArgs.layout, fileLayout, treeLayout)
+	}
+
 	url, err := registry.ParseURL(args[0])
 	if err != nil {
 		return err
@@ -131,7 +163,7 @@ func runPushArtifactCmd(cmd *cobra.Command, args []string) error {
 		rootCmd.Println(ssa.FmtUnstructured(object))
 	}
 
-	yml, err := ssa.ObjectsToYAML(objects)
+	files, err := artifactFiles(objects, pushArtifactArgs.layout)
 	if err != nil {
 		return err
 	}
@@ -147,10 +179,10 @@ func runPushArtifactCmd(cmd *cobra.Command, args []string) error {
 		logger.Println("pushing image", url)
 	}
 
-	digest, err := registry.Push(ctx, url, []byte(yml), &registry.Metadata{
+	digest, err := registry.Push(ctx, url, files, &registry.Metadata{
 		Version:        VERSION,
-		Checksum:       fmt.Sprintf("%x", sha256.Sum256([]byte(yml))),
 		Created:        time.Now().UTC().Format(time.RFC3339),
+		Layout:         pushArtifactArgs.layout,
 		SourceURL:      pushArtifactArgs.source,
 		SourceRevision: pushArtifactArgs.revision,
 	}, recipients)
@@ -200,3 +232,56 @@ func runPushArtifactCmd(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// artifactFiles returns the files of the artifact in the given layout, in the objects order.
+// In the tree layout, the namespaced objects are stored under 'namespaces/<namespace>/'
+// and the cluster-scoped ones under 'cluster/', with the kustomization at the root.
+func artifactFiles(objects []*unstructured.Unstructured, layout string) ([]registry.File, error) {
+	if layout == fileLayout {
+		yml, err := ssa.ObjectsToYAML(objects)
+		if err != nil {
+			return nil, err
+		}
+		return []registry.File{{Path: registry.ManifestsFile, Data: []byte(yml)}}, nil
+	}
+
+	kustomization := kustypes.Kustomization{
+		TypeMeta: kustypes.TypeMeta{
+			APIVersion: kustypes.KustomizationVersion,
+			Kind:       kustypes.KustomizationKind,
+		},
+	}
+
+	var files []registry.File
+	paths := make(map[string]bool)
+	for _, object := range objects {
+		dir := "cluster"
+		if object.GetNamespace() != "" {
+			dir = path.Join("namespaces", object.GetNamespace())
+		}
+
+		kind := strings.ToLower(object.GetKind())
+		name := strings.ReplaceAll(object.GetName(), ":", "_")
+		filePath := path.Join(dir, fmt.Sprintf("%s-%s.yaml", kind, name))
+		if paths[filePath] {
+			// objects with the same kind and name from different API groups
+			filePath = path.Join(dir, fmt.Sprintf("%s.%s-%s.yaml", kind, object.GroupVersionKind().Group, name))
+		}
+		paths[filePath] = true
+
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, registry.File{Path: filePath, Data: data})
+		kustomization.Resources = append(kustomization.Resources, filePath)
+	}
+
+	data, err := yaml.Marshal(kustomization)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]registry.File{{Path: registry.KustomizationFile, Data: data}}, files...), nil
+}
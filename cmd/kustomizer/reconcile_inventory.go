@@ -0,0 +1,346 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"filippo.io/age"
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+	"github.com/stefanprodan/kustomizer/pkg/registry"
+)
+
+var reconcileInventoryCmd = &cobra.Command{
+	Use:     "inventory",
+	Aliases: []string{"inv"},
+	Short:   "Reconcile periodically applies the latest version of an OCI artifact to the cluster.",
+	Long: `The reconcile command runs as a long-lived process that periodically resolves the artifact tag,
+or the newest tag matching a semver range, and applies the artifact with pruning when its digest
+differs from the one recorded in the inventory. When the digest is unchanged, the objects are
+compared with the cluster state and re-applied if drift is detected.`,
+	Example: `  kustomizer reconcile inventory <name> -n <namespace> --artifact <oci url> [--semver <condition>] [--interval <duration>]
+
+  # Apply the newest 1.2.x version every five minutes
+  kustomizer reconcile inventory my-app -n apps --artifact oci://docker.io/user/repo --semver '~1.2' --interval 5m
+
+  # Follow a tag and wait for the objects to become ready after each apply
+  kustomizer reconcile inventory my-app -n apps --artifact oci://docker.io/user/repo:latest --wait
+
+  # Run a single reconciliation and exit
+  kustomizer reconcile inventory my-app -n apps --artifact oci://docker.io/user/repo --semver '>=1.0.0' --once
+`,
+	RunE: runReconcileInventoryCmd,
+}
+
+type reconcileInventoryFlags struct {
+	artifact        string
+	semverExp       string
+	interval        time.Duration
+	once            bool
+	wait            bool
+	force           bool
+	createNamespace bool
+	ageIdentities   string
+	lockTimeout     time.Duration
+	metricsAddr     string
+}
+
+var reconcileInventoryArgs = newReconcileInventoryFlags()
+
+func newReconcileInventoryFlags() reconcileInventoryFlags {
+	return reconcileInventoryFlags{
+		interval:    5 * time.Minute,
+		lockTimeout: time.Minute,
+		metricsAddr: ":9797",
+	}
+}
+
+func init() {
+	reconcileInventoryCmd.Flags().StringVarP(&reconcileInventoryArgs.artifact, "artifact", "a", "",
+		"OCI artifact URL in the format 'oci://registry/org/repo:tag', or 'oci://registry/org/repo' when a semver range is specified.")
+	reconcileInventoryCmd.Flags().StringVar(&reconcileInventoryArgs.semverExp, "semver", "",
+		"Apply the newest tag matching the semantic version constraint e.g. '~1.2'.")
+	reconcileInventoryCmd.Flags().DurationVar(&reconcileInventoryArgs.interval, "interval", reconcileInventoryArgs.interval,
+		"The interval at which the artifact is resolved and the inventory reconciled.")
+	reconcileInventoryCmd.Flags().BoolVar(&reconcileInventoryArgs.once, "once", false, "Run a single reconciliation and exit.")
+	reconcileInventoryCmd.Flags().BoolVar(&reconcileInventoryArgs.wait, "wait", false, "Wait for the applied Kubernetes objects to become ready.")
+	reconcileInventoryCmd.Flags().BoolVar(&reconcileInventoryArgs.force, "force", false, "Recreate objects that contain immutable fields changes.")
+	reconcileInventoryCmd.Flags().BoolVar(&reconcileInventoryArgs.createNamespace, "create-namespace", false, "Create the inventory namespace if not present.")
+	reconcileInventoryCmd.Flags().StringVar(&reconcileInventoryArgs.ageIdentities, "age-identities", "",
+		"Path to a file containing one or more age identities (private keys generated by age-keygen).")
+	reconcileInventoryCmd.Flags().DurationVar(&reconcileInventoryArgs.lockTimeout, "lock-timeout", reconcileInventoryArgs.lockTimeout,
+		"The length of time to wait for the inventory lock held by another operation to be released.")
+
+	reconcileInventoryCmd.Flags().StringVar(&reconcileInventoryArgs.metricsAddr, "metrics-addr", reconcileInventoryArgs.metricsAddr,
+		"The address the Prometheus metrics and the '/healthz' and '/readyz' endpoints bind to, an empty value disables the listener.")
+
+	reconcileCmd.AddCommand(reconcileInventoryCmd)
+}
+
+func runReconcileInventoryCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("you must specify an inventory name")
+	}
+	name := args[0]
+
+	if reconcileInventoryArgs.artifact == "" {
+		return fmt.Errorf("--artifact is required")
+	}
+
+	if reconcileInventoryArgs.interval <= 0 {
+		return fmt.Errorf("--interval must be greater than zero")
+	}
+
+	identities, err := registry.ParseAgeIdentities(reconcileInventoryArgs.ageIdentities)
+	if err != nil {
+		return fmt.Errorf("faild to read decryption keys: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	r := &inventoryReconciler{
+		name:       name,
+		namespace:  *kubeconfigArgs.Namespace,
+		artifact:   reconcileInventoryArgs.artifact,
+		semverExp:  reconcileInventoryArgs.semverExp,
+		identities: identities,
+	}
+
+	if reconcileInventoryArgs.once {
+		_, err := r.reconcile(ctx)
+		return err
+	}
+
+	if reconcileInventoryArgs.metricsAddr != "" {
+		if err := serveMetrics(ctx, reconcileInventoryArgs.metricsAddr); err != nil {
+			return err
+		}
+	}
+
+	logger.Println(fmt.Sprintf("reconciling inventory %s/%s every %s...", r.namespace, r.name, reconcileInventoryArgs.interval))
+
+	ticker := time.NewTicker(reconcileInventoryArgs.interval)
+	defer ticker.Stop()
+
+	for {
+		// the process is ready while the last reconciliation succeeded
+		_, err := r.reconcile(ctx)
+		if err != nil {
+			logger.Println(`✗`, err)
+		}
+		metrics.SetReady(err == nil)
+
+		select {
+		case <-ctx.Done():
+			logger.Println("reconciliation stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// inventoryReconciler applies an OCI artifact to the cluster and keeps the inventory in sync with it.
+// The manifests of the last pulled digest are cached, the artifact is pulled again only when the digest changes.
+type inventoryReconciler struct {
+	name       string
+	namespace  string
+	artifact   string
+	semverExp  string
+	identities []age.Identity
+
+	digest  string
+	objects []*unstructured.Unstructured
+	hooks   []*unstructured.Unstructured
+}
+
+// reconcile resolves the artifact digest and applies the artifact with pruning if the digest differs
+// from the one recorded in the inventory or if the cluster state drifted.
+// It returns the report of the apply operation, or nil if the inventory is up-to-date.
+func (r *inventoryReconciler) reconcile(ctx context.Context) (*changeReport, error) {
+	report, err := r.reconcileArtifact(ctx)
+	if err != nil && report == nil {
+		// the failures that occurred before applying the artifact are notified without a change summary
+		notify("reconcile", newChangeReport(inventory.NewInventory(r.name, r.namespace)), err)
+	}
+	return report, err
+}
+
+func (r *inventoryReconciler) reconcileArtifact(parent context.Context) (*changeReport, error) {
+	ctx, cancel := context.WithTimeout(parent, rootArgs.timeout)
+	defer cancel()
+
+	url, err := resolveArtifactURL(ctx, r.artifact, r.semverExp)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := registry.Digest(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s failed: %w", url, err)
+	}
+
+	resMgr, err := newManager()
+	if err != nil {
+		return nil, err
+	}
+
+	invStorage, err := newInventoryStorage(resMgr)
+	if err != nil {
+		return nil, err
+	}
+
+	current := inventory.NewInventory(r.name, r.namespace)
+	upToDate := false
+	if err := invStorage.GetInventory(ctx, current); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	} else {
+		upToDate = len(current.Artifacts) == 1 && current.Artifacts[0] == digest
+	}
+
+	if digest != r.digest {
+		logger.Println(fmt.Sprintf("pulling %s...", digest))
+		objects, _, err := buildManifests(ctx, "", nil, []string{registry.URLPrefix + digest}, nil, r.identities)
+		if err != nil {
+			return nil, err
+		}
+
+		objects, hooks, err := splitHooks(objects)
+		if err != nil {
+			return nil, err
+		}
+
+		r.digest, r.objects, r.hooks = digest, objects, hooks
+	}
+
+	objects := copyObjects(r.objects)
+	hooks := copyObjects(r.hooks)
+
+	if upToDate {
+		drifted, err := countDrift(ctx, resMgr, objects, r.name, r.namespace)
+		if err != nil {
+			return nil, err
+		}
+		metrics.RecordDrift(r.name, r.namespace, drifted)
+		if drifted == 0 {
+			logger.Println(fmt.Sprintf("inventory %s/%s is up to date with %s", r.namespace, r.name, url))
+			return nil, nil
+		}
+		logger.Println(fmt.Sprintf("drift detected in inventory %s/%s, reapplying %s...", r.namespace, r.name, url))
+	} else {
+		logger.Println(fmt.Sprintf("applying %s to inventory %s/%s...", url, r.namespace, r.name))
+	}
+
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return nil, err
+	}
+
+	newInventory := inventory.NewInventory(r.name, r.namespace)
+	newInventory.SetSource(registry.URLPrefix+ref.Context().String(), ref.Identifier(), []string{digest})
+	if err := newInventory.AddObjects(objects); err != nil {
+		return nil, fmt.Errorf("creating inventory failed, error: %w", err)
+	}
+
+	start := time.Now()
+	report, err := applyInventory(ctx, newInventory, objects, hooks, applyInventoryFlags{
+		wait:            reconcileInventoryArgs.wait,
+		force:           reconcileInventoryArgs.force,
+		prune:           true,
+		createNamespace: reconcileInventoryArgs.createNamespace,
+		lockTimeout:     reconcileInventoryArgs.lockTimeout,
+	})
+	metrics.RecordApply(r.name, r.namespace, start, report, err)
+	notify("reconcile", report, err)
+	if err == nil {
+		metrics.RecordDrift(r.name, r.namespace, 0)
+	}
+	return report, err
+}
+
+// resolveArtifactURL returns the artifact URL without the 'oci://' prefix.
+// If a semver range is specified, the URL points to the newest tag matching the range.
+func resolveArtifactURL(ctx context.Context, artifact, semverExp string) (string, error) {
+	if semverExp == "" {
+		return registry.ParseURL(artifact)
+	}
+
+	repo, err := registry.ParseRepositoryURL(artifact)
+	if err != nil {
+		return "", err
+	}
+
+	tags, err := registry.List(ctx, repo)
+	if err != nil {
+		return "", fmt.Errorf("listing tags of %s failed: %w", repo, err)
+	}
+
+	versions, err := filterSemverTags(tags, semverExp)
+	if err != nil {
+		return "", err
+	}
+
+	if len(versions) == 0 {
+		return "", fmt.Errorf("no tag of %s matches the semver range '%s'", repo, semverExp)
+	}
+
+	return fmt.Sprintf("%s:%s", repo, versions[0].Original()), nil
+}
+
+// countDrift returns the number of objects that are missing from the cluster or were modified out-of-band.
+func countDrift(ctx context.Context, resMgr *ssa.ResourceManager, objects []*unstructured.Unstructured, name, namespace string) (int, error) {
+	sort.Sort(ssa.SortableUnstructureds(objects))
+	resMgr.SetOwnerLabels(objects, name, namespace)
+
+	count := 0
+	for _, object := range objects {
+		fixReplicasConflict(object, objects)
+
+		change, _, _, err := driftObject(ctx, resMgr, object)
+		if err != nil {
+			return 0, err
+		}
+
+		if change.Action != string(ssa.UnchangedAction) {
+			logger.Println(change.Subject, "drifted")
+			count++
+		}
+	}
+	return count, nil
+}
+
+func copyObjects(objects []*unstructured.Unstructured) []*unstructured.Unstructured {
+	result := make([]*unstructured.Unstructured, len(objects))
+	for i, object := range objects {
+		result[i] = object.DeepCopy()
+	}
+	return result
+}
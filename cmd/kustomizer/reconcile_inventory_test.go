@@ -0,0 +1,121 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestReconcileInventory(t *testing.T) {
+	g := NewWithT(t)
+	id := "reconcile-" + randStringRunes(5)
+	repo := fmt.Sprintf("oci://%s/%s", registryHost, id)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	pushVersion := func(tag, objectName string) {
+		dir, err := makeTestDir(id, testManifests(objectName, id, false))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"push artifact %s:%s -k %s",
+			repo,
+			tag,
+			dir,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+
+	reconcile := func() string {
+		output, err := executeCommand(fmt.Sprintf(
+			"reconcile inv %s -n %s -a %s --semver '~1.0' --once",
+			id,
+			id,
+			repo,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		return output
+	}
+
+	configMap := func(name string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: id,
+			},
+		}
+	}
+
+	t.Run("applies the newest matching version", func(t *testing.T) {
+		pushVersion("1.0.0", id+"-0")
+		pushVersion("2.0.0", id+"-2")
+
+		output := reconcile()
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`applying %s/%s:1.0.0`, registryHost, id)))
+
+		cm := configMap(id + "-0")
+		err := envTestClient.Get(context.Background(), client.ObjectKeyFromObject(cm), cm)
+		g.Expect(err).NotTo(HaveOccurred())
+	})
+
+	t.Run("skips an up-to-date inventory", func(t *testing.T) {
+		output := reconcile()
+		g.Expect(output).To(MatchRegexp("is up to date"))
+	})
+
+	t.Run("reapplies on drift", func(t *testing.T) {
+		cm := configMap(id + "-0")
+		err := envTestClient.Get(context.Background(), client.ObjectKeyFromObject(cm), cm)
+		g.Expect(err).NotTo(HaveOccurred())
+		cm.Data["key"] = "drifted"
+		err = envTestClient.Update(context.Background(), cm)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output := reconcile()
+		g.Expect(output).To(MatchRegexp("drift detected"))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(cm), cm)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(cm.Data["key"]).To(Equal("test"))
+	})
+
+	t.Run("upgrades and prunes", func(t *testing.T) {
+		pushVersion("1.0.1", id+"-1")
+
+		output := reconcile()
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`applying %s/%s:1.0.1`, registryHost, id)))
+
+		cm := configMap(id + "-1")
+		err := envTestClient.Get(context.Background(), client.ObjectKeyFromObject(cm), cm)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		cm = configMap(id + "-0")
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(cm), cm)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/yaml"
+
+	"github.com/stefanprodan/kustomizer/pkg/diff"
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+// changeReport is the machine-readable result of an apply, delete or diff operation.
+// The changes are logged as they are recorded, the report is printed at the end with '--output json|yaml'.
+type changeReport struct {
+	// Inventory holds the metadata of the inventory.
+	Inventory reportInventory `json:"inventory"`
+
+	// Changes holds the actions performed, or that would be performed, on the inventory objects.
+	Changes []reportChange `json:"changes"`
+
+	// Pruned holds the stale objects that were deleted, skipped or orphaned.
+	Pruned []reportChange `json:"pruned,omitempty"`
+
+	// Waits holds the outcome of waiting for the objects to become ready or to be terminated.
+	Waits []reportWait `json:"waits,omitempty"`
+
+	// Errors holds the errors encountered for each object.
+	Errors []reportError `json:"errors,omitempty"`
+}
+
+type reportInventory struct {
+	Name          string   `json:"name"`
+	Namespace     string   `json:"namespace"`
+	Source        string   `json:"source,omitempty"`
+	Revision      string   `json:"revision,omitempty"`
+	Generation    int64    `json:"generation,omitempty"`
+	LastAppliedAt string   `json:"lastAppliedTime,omitempty"`
+	Artifacts     []string `json:"artifacts,omitempty"`
+}
+
+type reportChange struct {
+	Subject string             `json:"subject"`
+	Action  string             `json:"action"`
+	Group   string             `json:"group"`
+	Version string             `json:"version"`
+	Kind    string             `json:"kind"`
+	Fields  []diff.FieldChange `json:"fields,omitempty"`
+}
+
+type reportWait struct {
+	Phase    string `json:"phase"`
+	Objects  int    `json:"objects"`
+	Duration string `json:"duration"`
+	Ready    bool   `json:"ready"`
+	Error    string `json:"error,omitempty"`
+}
+
+type reportError struct {
+	Subject string `json:"subject,omitempty"`
+	Error   string `json:"error"`
+}
+
+func newChangeReport(i *inventory.Inventory) *changeReport {
+	r := &changeReport{Changes: []reportChange{}}
+	r.SetInventory(i)
+	return r
+}
+
+// SetInventory records the inventory metadata.
+func (r *changeReport) SetInventory(i *inventory.Inventory) {
+	r.Inventory = reportInventory{
+		Name:          i.Name,
+		Namespace:     i.Namespace,
+		Source:        i.Source,
+		Revision:      i.Revision,
+		Generation:    i.Generation,
+		LastAppliedAt: i.LastAppliedAt,
+		Artifacts:     i.Artifacts,
+	}
+}
+
+// AddChange logs and records the given change set entry.
+func (r *changeReport) AddChange(entry ssa.ChangeSetEntry, suffix ...string) {
+	logger.Println(append([]interface{}{entry.String()}, toInterfaces(suffix)...)...)
+	r.RecordChange(entry)
+}
+
+// RecordChange records the given change set entry without logging it.
+func (r *changeReport) RecordChange(entry ssa.ChangeSetEntry) {
+	r.Changes = append(r.Changes, newReportChange(entry))
+}
+
+// RecordDiff records the given change set entry along with the field level differences of the object.
+func (r *changeReport) RecordDiff(entry ssa.ChangeSetEntry, fields []diff.FieldChange) {
+	change := newReportChange(entry)
+	change.Fields = fields
+	r.Changes = append(r.Changes, change)
+}
+
+// AddPruned logs and records the outcome of pruning a stale object.
+func (r *changeReport) AddPruned(entry ssa.ChangeSetEntry, suffix ...string) {
+	logger.Println(append([]interface{}{entry.String()}, toInterfaces(suffix)...)...)
+	r.RecordPruned(entry)
+}
+
+// RecordPruned records the outcome of pruning a stale object without logging it.
+func (r *changeReport) RecordPruned(entry ssa.ChangeSetEntry) {
+	r.Pruned = append(r.Pruned, newReportChange(entry))
+}
+
+// AddError records an error, the subject is optional.
+func (r *changeReport) AddError(subject string, err error) {
+	r.Errors = append(r.Errors, reportError{Subject: subject, Error: err.Error()})
+}
+
+// AddWait records the duration and the outcome of a wait operation.
+func (r *changeReport) AddWait(phase string, objects int, start time.Time, err error) {
+	wait := reportWait{
+		Phase:    phase,
+		Objects:  objects,
+		Duration: time.Since(start).Round(time.Millisecond).String(),
+		Ready:    err == nil,
+	}
+	if err != nil {
+		wait.Error = err.Error()
+	}
+	r.Waits = append(r.Waits, wait)
+}
+
+// Count returns the number of changes and pruned objects with the given action.
+func (r *changeReport) Count(action string) int {
+	count := 0
+	for _, change := range append(r.Changes, r.Pruned...) {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// newChangeSetEntry returns a change set entry for the given object and action,
+// for operations that are not performed by the resource manager.
+func newChangeSetEntry(obj *unstructured.Unstructured, action ssa.Action) ssa.ChangeSetEntry {
+	return ssa.ChangeSetEntry{
+		ObjMetadata:  object.UnstructuredToObjMetadata(obj),
+		GroupVersion: obj.GroupVersionKind().Version,
+		Subject:      ssa.FmtUnstructured(obj),
+		Action:       string(action),
+	}
+}
+
+func newReportChange(entry ssa.ChangeSetEntry) reportChange {
+	gv, _ := schema.ParseGroupVersion(entry.GroupVersion)
+	return reportChange{
+		Subject: entry.Subject,
+		Action:  entry.Action,
+		Group:   entry.ObjMetadata.GroupKind.Group,
+		Version: gv.Version,
+		Kind:    entry.ObjMetadata.GroupKind.Kind,
+	}
+}
+
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
+
+// validateOutputFormat returns an error if the output format is not supported.
+func validateOutputFormat(format string) error {
+	switch format {
+	case "", "json", "yaml":
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format '%s', can be 'json' or 'yaml'", format)
+	}
+}
+
+// printReport writes the report in the given format, nothing is printed if the format is empty.
+func printReport(w io.Writer, format string, report interface{}) error {
+	var data []byte
+	var err error
+	switch format {
+	case "":
+		return nil
+	case "json":
+		data, err = json.MarshalIndent(report, "", "  ")
+	case "yaml":
+		data, err = yaml.Marshal(report)
+	default:
+		return validateOutputFormat(format)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
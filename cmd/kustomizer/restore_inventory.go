@@ -0,0 +1,178 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+	"github.com/stefanprodan/kustomizer/pkg/registry"
+)
+
+var restoreInventoryCmd = &cobra.Command{
+	Use:     "inventory",
+	Aliases: []string{"inv"},
+	Short:   "Restore applies an inventory exported with 'kustomizer export inventory'.",
+	Long: `The restore command reads an inventory export from a directory or tarball and reconciles its objects using server-side apply.
+If the export contains the live objects, these are applied as they were at the time of the export,
+otherwise the objects are built from the OCI artifacts digests recorded in the inventory.
+The inventory name defaults to the exported one, and is stored in the namespace specified with '--namespace'.`,
+	Example: `  kustomizer restore inventory [name] -n <namespace> --from <path> [--target-namespace <namespace>]
+
+  # Restore an inventory to another cluster
+  kustomizer restore inventory -n apps --from ./backup/my-app.tar.gz --context prod-2 --create-namespace --wait
+
+  # Restore an inventory under a different name and move its objects to another namespace
+  kustomizer restore inventory my-app-copy -n apps-copy --from ./backup/my-app --target-namespace apps-copy
+`,
+	RunE: runRestoreInventoryCmd,
+}
+
+type restoreInventoryFlags struct {
+	from            string
+	targetNamespace string
+	wait            bool
+	force           bool
+	prune           bool
+	createNamespace bool
+	ageIdentities   string
+	lockTimeout     time.Duration
+}
+
+var restoreInventoryArgs = newRestoreInventoryFlags()
+
+func newRestoreInventoryFlags() restoreInventoryFlags {
+	return restoreInventoryFlags{
+		lockTimeout: time.Minute,
+	}
+}
+
+func init() {
+	restoreInventoryCmd.Flags().StringVar(&restoreInventoryArgs.from, "from", "",
+		"Path to the directory or the tarball ('.tar.gz' or '.tgz') containing the inventory export.")
+	restoreInventoryCmd.Flags().StringVar(&restoreInventoryArgs.targetNamespace, "target-namespace", "",
+		"Override the namespace of the namespaced objects.")
+	restoreInventoryCmd.Flags().BoolVar(&restoreInventoryArgs.wait, "wait", false, "Wait for the applied Kubernetes objects to become ready.")
+	restoreInventoryCmd.Flags().BoolVar(&restoreInventoryArgs.force, "force", false, "Recreate objects that contain immutable fields changes.")
+	restoreInventoryCmd.Flags().BoolVar(&restoreInventoryArgs.prune, "prune", false, "Delete stale objects from the cluster.")
+	restoreInventoryCmd.Flags().BoolVar(&restoreInventoryArgs.createNamespace, "create-namespace", false, "Create the inventory namespace if not present.")
+	restoreInventoryCmd.Flags().StringVar(&restoreInventoryArgs.ageIdentities, "age-identities", "",
+		"Path to a file containing one or more age identities (private keys generated by age-keygen).")
+	restoreInventoryCmd.Flags().DurationVar(&restoreInventoryArgs.lockTimeout, "lock-timeout", restoreInventoryArgs.lockTimeout,
+		"The length of time to wait for the inventory lock held by another operation to be released.")
+
+	restoreCmd.AddCommand(restoreInventoryCmd)
+}
+
+func runRestoreInventoryCmd(cmd *cobra.Command, args []string) error {
+	if restoreInventoryArgs.from == "" {
+		return fmt.Errorf("--from is required")
+	}
+
+	files, err := readExport(restoreInventoryArgs.from)
+	if err != nil {
+		return fmt.Errorf("reading %s failed: %w", restoreInventoryArgs.from, err)
+	}
+
+	data, ok := files[exportInventoryFile]
+	if !ok {
+		return fmt.Errorf("%s not found in %s", exportInventoryFile, restoreInventoryArgs.from)
+	}
+
+	exported := &inventory.Inventory{}
+	if err := json.Unmarshal(data, exported); err != nil {
+		return fmt.Errorf("decoding %s failed: %w", exportInventoryFile, err)
+	}
+
+	name := exported.Name
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	var objects []*unstructured.Unstructured
+	digests := exported.Artifacts
+	if yml, ok := files[exportObjectsFile]; ok {
+		logger.Println(fmt.Sprintf("reading objects from %s...", exportObjectsFile))
+		objects, err = ssa.ReadObjects(bytes.NewReader(yml))
+		if err != nil {
+			return fmt.Errorf("%s: %w", exportObjectsFile, err)
+		}
+
+		// the hooks are not part of the live objects, the pre-delete hooks are restored from the inventory
+		objects = append(objects, exported.DeleteHooks...)
+	} else {
+		if len(exported.Artifacts) == 0 {
+			return fmt.Errorf("the export contains no objects and the inventory has no artifacts, " +
+				"use 'kustomizer export inventory --include-objects' for inventories applied from local manifests")
+		}
+
+		identities, err := registry.ParseAgeIdentities(restoreInventoryArgs.ageIdentities)
+		if err != nil {
+			return fmt.Errorf("faild to read decryption keys: %w", err)
+		}
+
+		var artifacts []string
+		for _, digest := range exported.Artifacts {
+			artifacts = append(artifacts, registry.URLPrefix+digest)
+		}
+
+		logger.Println("building inventory...")
+		objects, digests, err = buildManifests(ctx, "", nil, artifacts, nil, identities)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ns := restoreInventoryArgs.targetNamespace; ns != "" {
+		for _, object := range objects {
+			if object.GetNamespace() != "" {
+				object.SetNamespace(ns)
+			}
+		}
+	}
+
+	objects, hooks, err := splitHooks(objects)
+	if err != nil {
+		return err
+	}
+
+	newInventory := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
+	newInventory.SetSource(exported.Source, exported.Revision, digests)
+	if err := newInventory.AddObjects(objects); err != nil {
+		return fmt.Errorf("creating inventory failed, error: %w", err)
+	}
+
+	_, err = applyInventory(ctx, newInventory, objects, hooks, applyInventoryFlags{
+		wait:            restoreInventoryArgs.wait,
+		force:           restoreInventoryArgs.force,
+		prune:           restoreInventoryArgs.prune,
+		createNamespace: restoreInventoryArgs.createNamespace,
+		lockTimeout:     restoreInventoryArgs.lockTimeout,
+	})
+	return err
+}
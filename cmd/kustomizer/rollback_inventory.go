@@ -0,0 +1,196 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+	"github.com/stefanprodan/kustomizer/pkg/registry"
+)
+
+var rollbackInventoryCmd = &cobra.Command{
+	Use:     "inventory",
+	Aliases: []string{"inv"},
+	Short:   "Rollback re-applies the OCI artifacts recorded in a previous revision of the given inventory.",
+	Long: `The rollback command pulls the OCI artifacts by the digests recorded in the target revision,
+reconciles the Kubernetes objects using server-side apply, prunes the objects that are not part of the target revision,
+and records the rollback as a new inventory revision.
+Only the revisions applied exclusively from OCI artifacts, without local manifests or patches, can be rolled back.`,
+	Example: `  kustomizer rollback inventory <name> -n <namespace> [--to-revision <number>|previous] --wait --force
+
+  # Rollback an inventory to the previous revision
+  kustomizer rollback inventory my-app -n apps
+
+  # Rollback an inventory to a specific revision and wait for all resources to become ready
+  kustomizer rollback inventory my-app -n apps --to-revision 3 --wait
+
+  # Rollback an inventory applied from encrypted OCI artifacts
+  kustomizer rollback inventory my-app -n apps --age-identities ./keys/id.txt
+`,
+	RunE: runRollbackInventoryCmd,
+}
+
+type rollbackInventoryFlags struct {
+	toRevision    string
+	wait          bool
+	force         bool
+	ageIdentities string
+	lockTimeout   time.Duration
+}
+
+var rollbackInventoryArgs = newRollbackInventoryFlags()
+
+func newRollbackInventoryFlags() rollbackInventoryFlags {
+	return rollbackInventoryFlags{
+		toRevision:  "previous",
+		lockTimeout: time.Minute,
+	}
+}
+
+func init() {
+	rollbackInventoryCmd.Flags().StringVar(&rollbackInventoryArgs.toRevision, "to-revision", rollbackInventoryArgs.toRevision,
+		"The revision number to rollback to, or 'previous' for the revision before the current one.")
+	rollbackInventoryCmd.Flags().BoolVar(&rollbackInventoryArgs.wait, "wait", false, "Wait for the applied Kubernetes objects to become ready.")
+	rollbackInventoryCmd.Flags().BoolVar(&rollbackInventoryArgs.force, "force", false, "Recreate objects that contain immutable fields changes.")
+	rollbackInventoryCmd.Flags().StringVar(&rollbackInventoryArgs.ageIdentities, "age-identities", "",
+		"Path to a file containing one or more age identities (private keys generated by age-keygen).")
+	rollbackInventoryCmd.Flags().DurationVar(&rollbackInventoryArgs.lockTimeout, "lock-timeout", rollbackInventoryArgs.lockTimeout,
+		"The length of time to wait for the inventory lock held by another operation to be released.")
+
+	rollbackCmd.AddCommand(rollbackInventoryCmd)
+}
+
+func runRollbackInventoryCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("you must specify an inventory name")
+	}
+	name := args[0]
+
+	identities, err := registry.ParseAgeIdentities(rollbackInventoryArgs.ageIdentities)
+	if err != nil {
+		return fmt.Errorf("faild to read decryption keys: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	resMgr, err := newManager()
+	if err != nil {
+		return err
+	}
+
+	invStorage, err := newInventoryStorage(resMgr)
+	if err != nil {
+		return err
+	}
+
+	logger.Println("retrieving inventory...")
+	currentInventory := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
+	if err := invStorage.GetInventory(ctx, currentInventory); err != nil {
+		return err
+	}
+
+	targetInventory, err := getRollbackRevision(ctx, invStorage, currentInventory, rollbackInventoryArgs.toRevision)
+	if err != nil {
+		return err
+	}
+
+	if len(targetInventory.Artifacts) == 0 {
+		return fmt.Errorf("revision %d has no artifacts, only revisions applied from OCI artifacts can be rolled back",
+			targetInventory.Generation)
+	}
+
+	var artifacts []string
+	for _, digest := range targetInventory.Artifacts {
+		artifacts = append(artifacts, registry.URLPrefix+digest)
+	}
+
+	logger.Println(fmt.Sprintf("building inventory from revision %d...", targetInventory.Generation))
+	objects, digests, err := buildManifests(ctx, "", nil, artifacts, nil, identities)
+	if err != nil {
+		return err
+	}
+
+	objects, hooks, err := splitHooks(objects)
+	if err != nil {
+		return err
+	}
+
+	newInventory := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
+	newInventory.SetSource(targetInventory.Source, targetInventory.Revision, digests)
+	if err := newInventory.AddObjects(objects); err != nil {
+		return fmt.Errorf("creating inventory failed, error: %w", err)
+	}
+
+	diff, err := targetInventory.Diff(newInventory)
+	if err != nil {
+		return err
+	}
+	if len(diff) > 0 {
+		return fmt.Errorf("the artifacts of revision %d do not contain %s, the revision was applied from local manifests or patches",
+			targetInventory.Generation, ssa.FmtUnstructured(diff[0]))
+	}
+
+	report, err := applyInventory(ctx, newInventory, objects, hooks, applyInventoryFlags{
+		wait:        rollbackInventoryArgs.wait,
+		force:       rollbackInventoryArgs.force,
+		prune:       true,
+		lockTimeout: rollbackInventoryArgs.lockTimeout,
+	})
+	notify("rollback", report, err)
+	return err
+}
+
+// getRollbackRevision returns the inventory revision matching the given number,
+// or the revision before the current one if 'previous' is specified.
+func getRollbackRevision(ctx context.Context, invStorage *inventory.Storage, current *inventory.Inventory, toRevision string) (*inventory.Inventory, error) {
+	target := inventory.NewInventory(current.Name, current.Namespace)
+
+	if toRevision != "previous" {
+		generation, err := strconv.ParseInt(toRevision, 10, 64)
+		if err != nil || generation < 1 {
+			return nil, fmt.Errorf("invalid revision '%s', must be a positive number or 'previous'", toRevision)
+		}
+		if generation == current.Generation {
+			return nil, fmt.Errorf("revision %d is the current revision", generation)
+		}
+		if err := invStorage.GetInventoryRevision(ctx, target, generation); err != nil {
+			return nil, err
+		}
+		return target, nil
+	}
+
+	revisions, err := invStorage.GetInventoryHistory(ctx, current)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rev := range revisions {
+		if rev.Generation < current.Generation {
+			return rev, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no previous revision found for inventory %s/%s", current.Namespace, current.Name)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRollbackInventory(t *testing.T) {
+	g := NewWithT(t)
+	id := "rollback-" + randStringRunes(5)
+	repo := fmt.Sprintf("oci://%s/%s", registryHost, id)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("applies two revisions", func(t *testing.T) {
+		for i, tag := range []string{"v1.0.0", "v2.0.0"} {
+			dir, err := makeTestDir(id, testManifests(fmt.Sprintf("%s-%d", id, i), id, false))
+			g.Expect(err).NotTo(HaveOccurred())
+
+			_, err = executeCommand(fmt.Sprintf(
+				"push artifact %s:%s -k %s",
+				repo,
+				tag,
+				dir,
+			))
+			g.Expect(err).NotTo(HaveOccurred())
+
+			output, err := executeCommand(fmt.Sprintf(
+				"apply inv %s -n %s -a %s:%s --prune",
+				id,
+				id,
+				repo,
+				tag,
+			))
+			g.Expect(err).NotTo(HaveOccurred())
+			t.Logf("\n%s", output)
+		}
+	})
+
+	t.Run("rollbacks to previous revision", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"rollback inv %s -n %s",
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp("revision 1"))
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id + "-0",
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id + "-1",
+				Namespace: id,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("records rollback as new revision", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"history inv %s -n %s",
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(`(?m)^3\s`))
+	})
+
+	t.Run("fails for unknown revision", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"rollback inv %s -n %s --to-revision 10",
+			id,
+			id,
+		))
+		g.Expect(err).To(HaveOccurred())
+	})
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var statusInventoryCmd = &cobra.Command{
+	Use:     "inventory",
+	Aliases: []string{"inv"},
+	Short:   "Status prints the health of the objects referenced by the given inventory.",
+	Long: `The status command retrieves the objects referenced by the inventory from the cluster
+and computes their status with kstatus (Current, InProgress, Failed, Terminating, NotFound or Unknown).
+The command exits with an error if any object is not Current.
+In watch mode, the status changes are printed until all objects are Current or the timeout expires.`,
+	Example: ` kustomizer status inventory <name> -n <namespace> [--watch]
+
+  # Print the status of the objects in an inventory
+  kustomizer status inv my-app -n apps
+
+  # Wait for all objects in an inventory to become ready
+  kustomizer status inv my-app -n apps --watch --timeout 5m
+`,
+	RunE: runStatusInventoryCmd,
+}
+
+type statusInventoryFlags struct {
+	watch    bool
+	interval time.Duration
+}
+
+var statusInventoryArgs = newStatusInventoryFlags()
+
+func newStatusInventoryFlags() statusInventoryFlags {
+	return statusInventoryFlags{
+		interval: 2 * time.Second,
+	}
+}
+
+func init() {
+	statusInventoryCmd.Flags().BoolVarP(&statusInventoryArgs.watch, "watch", "w", false,
+		"Watch for status changes until all objects are Current.")
+	statusInventoryCmd.Flags().DurationVar(&statusInventoryArgs.interval, "interval", statusInventoryArgs.interval,
+		"The interval at which the objects status is polled in watch mode.")
+
+	statusCmd.AddCommand(statusInventoryCmd)
+}
+
+func runStatusInventoryCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("you must specify an inventory name")
+	}
+	name := args[0]
+
+	kubeClient, err := newKubeClient(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("client init failed: %w", err)
+	}
+
+	statusPoller, err := newKubeStatusPoller(kubeconfigArgs)
+	if err != nil {
+		return fmt.Errorf("status poller init failed: %w", err)
+	}
+
+	resMgr := ssa.NewResourceManager(kubeClient, statusPoller, inventoryOwner)
+
+	invStorage, err := newInventoryStorage(resMgr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	i := inventory.NewInventory(name, *kubeconfigArgs.Namespace)
+	if err := invStorage.GetInventory(ctx, i); err != nil {
+		return err
+	}
+
+	metas, err := i.ListMeta()
+	if err != nil {
+		return err
+	}
+
+	if len(metas) == 0 {
+		logger.Println(fmt.Sprintf("inventory %s/%s has no objects", i.Namespace, i.Name))
+		return nil
+	}
+
+	statuses, err := pollInventoryStatus(ctx, statusPoller, metas, statusInventoryArgs.watch, statusInventoryArgs.interval)
+	if err != nil {
+		return err
+	}
+
+	notReady := 0
+	var rows [][]string
+	for _, meta := range metas {
+		st, msg := status.UnknownStatus, ""
+		if rs, ok := statuses[meta]; ok {
+			st, msg = rs.Status, rs.Message
+			if rs.Error != nil {
+				msg = rs.Error.Error()
+			}
+		}
+		if st != status.CurrentStatus {
+			notReady++
+		}
+		rows = append(rows, []string{ssa.FmtObjMetadata(meta), st.String(), msg})
+	}
+
+	if !statusInventoryArgs.watch {
+		printTable(rootCmd.OutOrStdout(), []string{"object", "status", "message"}, rows)
+	}
+
+	if notReady > 0 {
+		return fmt.Errorf("%v object(s) not ready in inventory %s/%s", notReady, i.Namespace, i.Name)
+	}
+
+	if statusInventoryArgs.watch {
+		logger.Println("all resources are ready")
+	}
+	return nil
+}
+
+// pollInventoryStatus computes the status of the given objects with kstatus.
+// In watch mode, every status change is printed until all objects are Current or the context expires,
+// otherwise the polling stops after the status of every object has been computed once.
+func pollInventoryStatus(ctx context.Context, statusPoller *polling.StatusPoller, metas object.ObjMetadataSet,
+	watch bool, interval time.Duration) (map[object.ObjMetadata]*event.ResourceStatus, error) {
+	pollCtx, pollCancel := context.WithCancel(ctx)
+	defer pollCancel()
+
+	events := statusPoller.Poll(pollCtx, metas, polling.PollOptions{PollInterval: interval})
+	statuses := make(map[object.ObjMetadata]*event.ResourceStatus, len(metas))
+
+	var pollErr error
+	for e := range events {
+		if e.Type == event.ErrorEvent {
+			pollErr = e.Error
+			break
+		}
+		if e.Type != event.ResourceUpdateEvent {
+			continue
+		}
+
+		statuses[e.Resource.Identifier] = e.Resource
+		if watch {
+			rootCmd.Println(fmt.Sprintf("%s %s %s",
+				ssa.FmtObjMetadata(e.Resource.Identifier), e.Resource.Status, e.Resource.Message))
+		}
+
+		if len(statuses) == len(metas) && (!watch || allCurrent(statuses)) {
+			break
+		}
+	}
+
+	// drain the channel to let the poller exit
+	pollCancel()
+	for range events {
+	}
+
+	return statuses, pollErr
+}
+
+func allCurrent(statuses map[object.ObjMetadata]*event.ResourceStatus) bool {
+	for _, rs := range statuses {
+		if rs.Status != status.CurrentStatus {
+			return false
+		}
+	}
+	return true
+}
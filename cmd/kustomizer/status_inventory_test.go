@@ -0,0 +1,90 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestStatusInventory(t *testing.T) {
+	g := NewWithT(t)
+	id := "status-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	output, err := executeCommand(fmt.Sprintf(
+		"apply inv %s -k %s -n %s",
+		id,
+		dir,
+		id,
+	))
+	g.Expect(err).NotTo(HaveOccurred())
+	t.Logf("\n%s", output)
+
+	t.Run("reports current objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"status inv %s -n %s",
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`ConfigMap/%s/%s\s+Current`, id, id)))
+	})
+
+	t.Run("waits for current objects", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"status inv %s -n %s --watch",
+			id,
+			id,
+		))
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`Secret/%s/%s Current`, id, id)))
+	})
+
+	t.Run("fails for missing objects", func(t *testing.T) {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id,
+				Namespace: id,
+			},
+		}
+		err := envTestClient.Delete(context.Background(), configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"status inv %s -n %s",
+			id,
+			id,
+		))
+		g.Expect(err).To(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(fmt.Sprintf(`ConfigMap/%s/%s\s+NotFound`, id, id)))
+	})
+}
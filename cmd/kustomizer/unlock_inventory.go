@@ -0,0 +1,73 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stefanprodan/kustomizer/pkg/inventory"
+)
+
+var unlockInventoryCmd = &cobra.Command{
+	Use:     "inventory",
+	Aliases: []string{"inv"},
+	Short:   "Unlock removes the lock held on the given inventory by an apply, rollback or delete operation.",
+	Long: `The unlock command deletes the Lease held on the given inventory regardless of its holder.
+It should be used only when the operation holding the lock was terminated before releasing it,
+otherwise concurrent operations could prune each other's objects.`,
+	Example: ` kustomizer unlock inventory <name> -n <namespace>
+
+  # Remove a stuck lock from an inventory
+  kustomizer unlock inv my-app -n apps
+`,
+	RunE: runUnlockInventoryCmd,
+}
+
+func init() {
+	unlockCmd.AddCommand(unlockInventoryCmd)
+}
+
+func runUnlockInventoryCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("you must specify an inventory name")
+	}
+	name := args[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	resMgr, err := newManager()
+	if err != nil {
+		return err
+	}
+
+	invStorage, err := newInventoryStorage(resMgr)
+	if err != nil {
+		return err
+	}
+
+	holder, err := invStorage.UnlockInventory(ctx, inventory.NewInventory(name, *kubeconfigArgs.Namespace))
+	if err != nil {
+		return err
+	}
+
+	logger.Println(fmt.Sprintf("lock held by '%s' on inventory %s/%s removed", holder, *kubeconfigArgs.Namespace, name))
+	return nil
+}
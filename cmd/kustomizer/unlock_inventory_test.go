@@ -0,0 +1,168 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestUnlockInventory(t *testing.T) {
+	g := NewWithT(t)
+	id := "lock-" + randStringRunes(5)
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dir, err := makeTestDir(id, testManifests(id, id, false))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	holder := "ci-pipeline"
+	duration := int32(time.Hour.Seconds())
+	now := metav1.NowMicro()
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "inv-" + id,
+			Namespace: id,
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		},
+	}
+	err = envTestClient.Create(context.Background(), lease)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("fails to apply a locked inventory", func(t *testing.T) {
+		_, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s --lock-timeout 1s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(MatchRegexp(holder))
+	})
+
+	t.Run("removes the lock", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"unlock inv %s -n %s",
+			id,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+		g.Expect(output).To(MatchRegexp(holder))
+	})
+
+	t.Run("applies and releases the lock", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -k %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		_, err = executeCommand(fmt.Sprintf(
+			"unlock inv %s -n %s",
+			id,
+			id,
+		))
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestLockInventoryNamespace(t *testing.T) {
+	g := NewWithT(t)
+	id := "lock-ns-" + randStringRunes(5)
+
+	dir, err := makeTestDir(id, []TestFile{
+		{
+			Name: "namespace.yaml",
+			Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: "%[1]s"
+`, id),
+		},
+		{
+			Name: "config.yaml",
+			Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: "%[1]s"
+  namespace: "%[1]s"
+data:
+  key: "test"
+`, id),
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("locks the inventory after creating its namespace", func(t *testing.T) {
+		output, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s",
+			id,
+			dir,
+			id,
+		))
+
+		g.Expect(err).NotTo(HaveOccurred())
+		t.Logf("\n%s", output)
+
+		configMap := &corev1.ConfigMap{}
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: id, Namespace: id}, configMap)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		lease := &coordinationv1.Lease{}
+		err = envTestClient.Get(context.Background(), client.ObjectKey{Name: "inv-" + id, Namespace: id}, lease)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("fails when the namespace is missing", func(t *testing.T) {
+		missing := id + "-missing"
+		_, err := executeCommand(fmt.Sprintf(
+			"apply inv %s -f %s -n %s",
+			missing,
+			path.Join(dir, "config.yaml"),
+			missing,
+		))
+
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("namespace " + missing + " not found"))
+	})
+}
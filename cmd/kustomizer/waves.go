@@ -0,0 +1,108 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/fluxcd/pkg/ssa"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// applyWaveAnnotation sets the order in which objects are applied,
+// objects without this annotation belong to wave 0.
+const applyWaveAnnotation = "kustomizer.dev/apply-wave"
+
+// objectWave is a group of objects that are applied or deleted together.
+type objectWave struct {
+	wave    int
+	objects []*unstructured.Unstructured
+}
+
+// getApplyWave returns the wave number set with the apply wave annotation.
+func getApplyWave(object *unstructured.Unstructured) (int, error) {
+	value, ok := object.GetAnnotations()[applyWaveAnnotation]
+	if !ok {
+		return 0, nil
+	}
+
+	wave, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s has an invalid %s annotation '%s', must be an integer",
+			ssa.FmtUnstructured(object), applyWaveAnnotation, value)
+	}
+	return wave, nil
+}
+
+// groupByApplyWave returns the objects grouped by wave in ascending order.
+func groupByApplyWave(objects []*unstructured.Unstructured) ([]objectWave, error) {
+	groups := make(map[int][]*unstructured.Unstructured)
+	for _, object := range objects {
+		wave, err := getApplyWave(object)
+		if err != nil {
+			return nil, err
+		}
+		groups[wave] = append(groups[wave], object)
+	}
+
+	return sortWaves(groups, false), nil
+}
+
+// groupByDeleteWave returns the objects grouped by wave in descending order.
+// The wave number is read from the in-cluster objects, as the inventory doesn't record annotations,
+// the objects that can't be retrieved belong to wave 0.
+func groupByDeleteWave(ctx context.Context, kubeClient client.Client, objects []*unstructured.Unstructured) []objectWave {
+	groups := make(map[int][]*unstructured.Unstructured)
+	for _, object := range objects {
+		wave := 0
+
+		existingObject := &unstructured.Unstructured{}
+		existingObject.SetGroupVersionKind(object.GroupVersionKind())
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(object), existingObject); err == nil {
+			if w, err := getApplyWave(existingObject); err == nil {
+				wave = w
+			}
+		}
+
+		groups[wave] = append(groups[wave], object)
+	}
+
+	waves := sortWaves(groups, true)
+	for _, w := range waves {
+		sort.Sort(sort.Reverse(ssa.SortableUnstructureds(w.objects)))
+	}
+	return waves
+}
+
+func sortWaves(groups map[int][]*unstructured.Unstructured, reverse bool) []objectWave {
+	waves := make([]objectWave, 0, len(groups))
+	for wave, objects := range groups {
+		waves = append(waves, objectWave{wave: wave, objects: objects})
+	}
+
+	sort.Slice(waves, func(i, j int) bool {
+		if reverse {
+			return waves[i].wave > waves[j].wave
+		}
+		return waves[i].wave < waves[j].wave
+	})
+	return waves
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/fluxcd/pkg/ssa"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -16,6 +18,30 @@ const (
 	KustomizerConfigApiVersion  = "kustomizer.dev/v1"
 	KustomizerFieldManagerName  = "kustomizer"
 	KustomizerFieldManagerGroup = "inventory.kustomizer.dev"
+	KustomizerHistoryLimit      = 10
+	KustomizerInventoryStorage  = "ConfigMap"
+	KustomizerLockTTL           = time.Minute
+	KustomizerPrunePolicy       = PruneDisabled
+	KustomizerWebhookRetries    = 3
+)
+
+const (
+	// PruneDisabled keeps the object in the cluster as is.
+	PruneDisabled = "disabled"
+
+	// PruneOrphan removes the ownership labels and keeps the object in the cluster.
+	PruneOrphan = "orphan"
+)
+
+const (
+	// WebhookGeneric posts the notification event as JSON.
+	WebhookGeneric = "generic"
+
+	// WebhookSlack posts a Slack-compatible message.
+	WebhookSlack = "slack"
+
+	// WebhookMSTeams posts a Microsoft Teams-compatible message card.
+	WebhookMSTeams = "msteams"
 )
 
 type Config struct {
@@ -27,6 +53,106 @@ type Config struct {
 
 	// FieldManager holds the manager name and group used for server-side apply.
 	FieldManager *FieldManager `json:"fieldManager,omitempty"`
+
+	// Inventory holds the settings of the inventory storage.
+	Inventory *InventoryOptions `json:"inventory,omitempty"`
+
+	// Prune holds the settings for the garbage collection of stale objects.
+	Prune *PruneOptions `json:"prune,omitempty"`
+
+	// Notifications holds the webhooks notified of the inventory operations outcome.
+	Notifications *NotificationOptions `json:"notifications,omitempty"`
+
+	// Diff holds the settings of the diff output.
+	Diff *DiffOptions `json:"diff,omitempty"`
+}
+
+type DiffOptions struct {
+	// Mask holds the fields whose values are masked in the diff output, in addition to the Secrets data.
+	Mask []FieldRule `json:"mask"`
+
+	// Ignore holds the fields excluded from the diff and drift detection,
+	// e.g. the fields set by controllers and mutating webhooks.
+	Ignore []IgnoreRule `json:"ignore"`
+}
+
+type FieldRule struct {
+	// Kind restricts the rule to the objects of the given Kubernetes API Kind, e.g. 'ConfigMap'.
+	// When empty, the rule applies to all kinds.
+	Kind string `json:"kind,omitempty"`
+
+	// Paths holds the JSON pointers of the fields, '*' matches any map key or list index,
+	// e.g. '/spec/template/spec/containers/*/env'.
+	Paths []string `json:"paths"`
+}
+
+// Matches returns true if the rule applies to the given kind.
+func (r FieldRule) Matches(kind string) bool {
+	return r.Kind == "" || r.Kind == kind
+}
+
+type IgnoreRule struct {
+	// Kind restricts the rule to the objects of the given Kubernetes API Kind, e.g. 'Deployment'.
+	// When empty, the rule applies to all kinds.
+	Kind string `json:"kind,omitempty"`
+
+	// Paths holds the JSON pointers of the ignored fields, '*' matches any map key or list index,
+	// e.g. '/spec/template/spec/containers/*/resources'.
+	Paths []string `json:"paths,omitempty"`
+
+	// Managers holds the names of the field managers whose fields are ignored, e.g. 'cainjector'.
+	Managers []string `json:"managers,omitempty"`
+}
+
+// Matches returns true if the rule applies to the given kind.
+func (r IgnoreRule) Matches(kind string) bool {
+	return r.Kind == "" || r.Kind == kind
+}
+
+type NotificationOptions struct {
+	// Webhooks holds the list of endpoints notified after apply, delete, rollback and reconcile operations.
+	Webhooks []Webhook `json:"webhooks"`
+
+	// Retries sets how many times a failed notification is resent, defaults to 3.
+	Retries *int `json:"retries,omitempty"`
+}
+
+type Webhook struct {
+	// Type sets the payload format, can be 'generic', 'slack' or 'msteams'.
+	Type string `json:"type"`
+
+	// URL is the address of the webhook endpoint.
+	URL string `json:"url"`
+
+	// Headers holds extra HTTP headers sent with each request, e.g. for authentication.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type PruneOptions struct {
+	// ProtectedKinds holds the list of Kubernetes API Kinds that are never deleted
+	// by prune or delete operations, e.g. 'PersistentVolumeClaim' or 'Certificate.cert-manager.io'.
+	ProtectedKinds []string `json:"protectedKinds"`
+
+	// Policy sets what happens with the protected objects when they are removed from an inventory,
+	// can be 'disabled' to keep the objects as they are, or 'orphan' to remove the ownership labels.
+	Policy string `json:"policy"`
+}
+
+type InventoryOptions struct {
+	// HistoryLimit sets the maximum number of revisions retained for each inventory, defaults to 10.
+	// When set to zero, the revision history is disabled.
+	HistoryLimit *int `json:"historyLimit,omitempty"`
+
+	// Storage sets the Kubernetes kind used to store the inventories,
+	// can be ConfigMap or Secret.
+	Storage string `json:"storage"`
+
+	// Compression enables the gzip compression of the inventory entries.
+	Compression bool `json:"compression"`
+
+	// LockTTL sets the lease duration of the inventory locks, a lock that isn't renewed
+	// within this interval can be taken over by another operation.
+	LockTTL metav1.Duration `json:"lockTTL,omitempty"`
 }
 
 type FieldManager struct {
@@ -56,8 +182,12 @@ func NewConfig() *Config {
 			Kind:       KustomizerConfigKind,
 			APIVersion: KustomizerConfigApiVersion,
 		},
-		ApplyOrder:   defaultKindOrder(),
-		FieldManager: defaultFieldManager(),
+		ApplyOrder:    defaultKindOrder(),
+		FieldManager:  defaultFieldManager(),
+		Inventory:     defaultInventoryOptions(),
+		Prune:         defaultPruneOptions(),
+		Notifications: defaultNotificationOptions(),
+		Diff:          defaultDiffOptions(),
 	}
 }
 
@@ -75,6 +205,35 @@ func defaultFieldManager() *FieldManager {
 	}
 }
 
+func defaultInventoryOptions() *InventoryOptions {
+	return &InventoryOptions{
+		HistoryLimit: intPtr(KustomizerHistoryLimit),
+		Storage:      KustomizerInventoryStorage,
+		LockTTL:      metav1.Duration{Duration: KustomizerLockTTL},
+	}
+}
+
+func defaultPruneOptions() *PruneOptions {
+	return &PruneOptions{
+		ProtectedKinds: []string{},
+		Policy:         KustomizerPrunePolicy,
+	}
+}
+
+func defaultNotificationOptions() *NotificationOptions {
+	return &NotificationOptions{
+		Webhooks: []Webhook{},
+		Retries:  intPtr(KustomizerWebhookRetries),
+	}
+}
+
+func defaultDiffOptions() *DiffOptions {
+	return &DiffOptions{
+		Mask:   []FieldRule{},
+		Ignore: []IgnoreRule{},
+	}
+}
+
 // DefaultConfigPath returns '$HOME/.kustomizer/config'
 func DefaultConfigPath() (string, error) {
 	homeDir, err := os.UserHomeDir()
@@ -117,6 +276,85 @@ func Read(configPath string) (*Config, error) {
 		cfg.FieldManager = defaultFieldManager()
 	}
 
+	if cfg.Inventory == nil {
+		cfg.Inventory = defaultInventoryOptions()
+	}
+
+	if cfg.Inventory.HistoryLimit == nil {
+		cfg.Inventory.HistoryLimit = intPtr(KustomizerHistoryLimit)
+	}
+
+	if *cfg.Inventory.HistoryLimit < 0 {
+		return nil, fmt.Errorf("the inventory history limit can't be negative")
+	}
+
+	switch ttl := cfg.Inventory.LockTTL.Duration; {
+	case ttl == 0:
+		cfg.Inventory.LockTTL = metav1.Duration{Duration: KustomizerLockTTL}
+	case ttl < time.Second:
+		return nil, fmt.Errorf("the inventory lock TTL must be at least one second")
+	}
+
+	switch cfg.Inventory.Storage {
+	case "":
+		cfg.Inventory.Storage = KustomizerInventoryStorage
+	case "ConfigMap", "Secret":
+	default:
+		return nil, fmt.Errorf("the inventory storage can be ConfigMap or Secret")
+	}
+
+	if cfg.Prune == nil {
+		cfg.Prune = defaultPruneOptions()
+	}
+
+	switch cfg.Prune.Policy {
+	case "":
+		cfg.Prune.Policy = KustomizerPrunePolicy
+	case PruneDisabled, PruneOrphan:
+	default:
+		return nil, fmt.Errorf("the prune policy can be %s or %s", PruneDisabled, PruneOrphan)
+	}
+
+	if cfg.Notifications == nil {
+		cfg.Notifications = defaultNotificationOptions()
+	}
+
+	if cfg.Notifications.Retries == nil {
+		cfg.Notifications.Retries = intPtr(KustomizerWebhookRetries)
+	}
+
+	if *cfg.Notifications.Retries < 0 {
+		return nil, fmt.Errorf("the notification retries can't be negative")
+	}
+
+	for _, webhook := range cfg.Notifications.Webhooks {
+		switch webhook.Type {
+		case WebhookGeneric, WebhookSlack, WebhookMSTeams:
+		default:
+			return nil, fmt.Errorf("the webhook type can be %s, %s or %s", WebhookGeneric, WebhookSlack, WebhookMSTeams)
+		}
+
+		if webhook.URL == "" {
+			return nil, fmt.Errorf("the webhook URL can't be empty")
+		}
+	}
+
+	if cfg.Diff == nil {
+		cfg.Diff = defaultDiffOptions()
+	}
+
+	for _, rule := range cfg.Diff.Mask {
+		if err := validateFieldRule(rule); err != nil {
+			return nil, fmt.Errorf("invalid diff mask rule: %w", err)
+		}
+	}
+
+	for _, rule := range cfg.Diff.Ignore {
+		if err := validateIgnoreRule(rule); err != nil {
+			return nil, fmt.Errorf("invalid diff ignore rule: %w", err)
+		}
+	}
+
 	if cfg.FieldManager.Name == "" {
 		return nil, fmt.Errorf("the filed manager name can't be empty")
 	}
@@ -128,6 +366,39 @@ func Read(configPath string) (*Config, error) {
 	return cfg, nil
 }
 
+func intPtr(v int) *int {
+	return &v
+}
+
+func validateFieldRule(rule FieldRule) error {
+	if len(rule.Paths) == 0 {
+		return fmt.Errorf("at least one path is required")
+	}
+	for _, path := range rule.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("path '%s' must be a JSON pointer starting with '/'", path)
+		}
+	}
+	return nil
+}
+
+func validateIgnoreRule(rule IgnoreRule) error {
+	if len(rule.Paths) == 0 && len(rule.Managers) == 0 {
+		return fmt.Errorf("at least one path or field manager is required")
+	}
+	for _, path := range rule.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("path '%s' must be a JSON pointer starting with '/'", path)
+		}
+	}
+	for _, manager := range rule.Managers {
+		if manager == "" {
+			return fmt.Errorf("the field manager name can't be empty")
+		}
+	}
+	return nil
+}
+
 // Write saves the config at the given path, if no path is specified
 // it will create or override '$HOME/.kustomizer/config'.
 func (c *Config) Write(configPath string) error {
@@ -0,0 +1,49 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"strings"
+)
+
+const (
+	colorReset = "\x1b[0m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// Colorize adds ANSI colours to the lines of a unified diff,
+// red for the removed lines, green for the added lines and cyan for the hunk headers.
+func Colorize(unified string) string {
+	if unified == "" {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimSuffix(unified, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = colorCyan + line + colorReset
+		case strings.HasPrefix(line, "-"):
+			lines[i] = colorRed + line + colorReset
+		case strings.HasPrefix(line, "+"):
+			lines[i] = colorGreen + line + colorReset
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
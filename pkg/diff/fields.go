@@ -0,0 +1,111 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The types of field changes.
+const (
+	FieldAdded   = "added"
+	FieldRemoved = "removed"
+	FieldChanged = "changed"
+)
+
+// FieldChange is a difference between two objects at the given field path.
+type FieldChange struct {
+	// Path is the JSON pointer of the field, e.g. '/spec/template/spec/containers/0/image'.
+	Path string `json:"path"`
+
+	// Type can be 'added', 'removed' or 'changed'.
+	Type string `json:"type"`
+
+	// Old holds the value of the field in the first object.
+	Old interface{} `json:"old,omitempty"`
+
+	// New holds the value of the field in the second object.
+	New interface{} `json:"new,omitempty"`
+}
+
+// Fields returns the field level differences between two objects, ordered by path.
+// Maps are compared key by key and lists item by item, any other value is compared as a whole.
+func Fields(from, to map[string]interface{}) []FieldChange {
+	changes := []FieldChange{}
+	walk("", from, to, &changes)
+	return changes
+}
+
+func walk(path string, from, to interface{}, changes *[]FieldChange) {
+	switch fromValue := from.(type) {
+	case map[string]interface{}:
+		if toValue, ok := to.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(fromValue)+len(toValue))
+			for key := range fromValue {
+				keys = append(keys, key)
+			}
+			for key := range toValue {
+				if _, ok := fromValue[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				childPath := path + "/" + EscapePointer(key)
+				oldChild, inFrom := fromValue[key]
+				newChild, inTo := toValue[key]
+				switch {
+				case !inTo:
+					*changes = append(*changes, FieldChange{Path: childPath, Type: FieldRemoved, Old: oldChild})
+				case !inFrom:
+					*changes = append(*changes, FieldChange{Path: childPath, Type: FieldAdded, New: newChild})
+				default:
+					walk(childPath, oldChild, newChild, changes)
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if toValue, ok := to.([]interface{}); ok {
+			for i := 0; i < len(fromValue) || i < len(toValue); i++ {
+				childPath := path + "/" + strconv.Itoa(i)
+				switch {
+				case i >= len(toValue):
+					*changes = append(*changes, FieldChange{Path: childPath, Type: FieldRemoved, Old: fromValue[i]})
+				case i >= len(fromValue):
+					*changes = append(*changes, FieldChange{Path: childPath, Type: FieldAdded, New: toValue[i]})
+				default:
+					walk(childPath, fromValue[i], toValue[i], changes)
+				}
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, FieldChange{Path: path, Type: FieldChanged, Old: from, New: to})
+	}
+}
+
+// EscapePointer escapes a JSON pointer reference token as defined in RFC 6901.
+func EscapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// ManagedPaths returns the JSON pointers of the fields present in the object that are listed
+// in the given managed fields set, in the FieldsV1 format recorded by server-side apply.
+// The maps owned as a whole are skipped, as owning a map doesn't imply owning all its fields.
+func ManagedPaths(object map[string]interface{}, fieldsV1 []byte) ([]string, error) {
+	set := &fieldpath.Set{}
+	if err := set.FromJSON(bytes.NewReader(fieldsV1)); err != nil {
+		return nil, fmt.Errorf("failed to parse managed fields: %w", err)
+	}
+
+	var pointers []string
+	set.Iterate(func(path fieldpath.Path) {
+		pointer, field, ok := resolvePath(object, path)
+		if !ok {
+			return
+		}
+		if _, isMap := field.(map[string]interface{}); isMap && path[len(path)-1].FieldName != nil {
+			return
+		}
+		pointers = append(pointers, pointer)
+	})
+	return pointers, nil
+}
+
+// resolvePath returns the JSON pointer and the value of the field at the given path,
+// the list elements selected by keys or values are resolved to their index in the object.
+func resolvePath(object interface{}, path fieldpath.Path) (string, interface{}, bool) {
+	pointer := ""
+	current := object
+	for _, element := range path {
+		switch {
+		case element.FieldName != nil:
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return "", nil, false
+			}
+			child, ok := m[*element.FieldName]
+			if !ok {
+				return "", nil, false
+			}
+			pointer += "/" + EscapePointer(*element.FieldName)
+			current = child
+		case element.Index != nil:
+			l, ok := current.([]interface{})
+			if !ok || *element.Index < 0 || *element.Index >= len(l) {
+				return "", nil, false
+			}
+			pointer += "/" + strconv.Itoa(*element.Index)
+			current = l[*element.Index]
+		default:
+			l, ok := current.([]interface{})
+			if !ok {
+				return "", nil, false
+			}
+			i := findElement(l, element)
+			if i < 0 {
+				return "", nil, false
+			}
+			pointer += "/" + strconv.Itoa(i)
+			current = l[i]
+		}
+	}
+	return pointer, current, true
+}
+
+// findElement returns the index of the list item selected by the path element key or value, or -1.
+func findElement(list []interface{}, element fieldpath.PathElement) int {
+	for i, item := range list {
+		if element.Value != nil && value.Equals(value.NewValueInterface(item), *element.Value) {
+			return i
+		}
+		if element.Key == nil {
+			continue
+		}
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		matches := true
+		for _, field := range *element.Key {
+			v, ok := m[field.Name]
+			if !ok || !value.Equals(value.NewValueInterface(v), field.Value) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return i
+		}
+	}
+	return -1
+}
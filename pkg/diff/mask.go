@@ -0,0 +1,67 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"reflect"
+)
+
+// The values replacing the masked fields.
+const (
+	// Mask replaces the values that are the same in both objects, or that exist in only one of them.
+	Mask = "***"
+
+	// MaskBefore replaces the value of a changed field in the first object.
+	MaskBefore = "*** (before)"
+
+	// MaskAfter replaces the value of a changed field in the second object.
+	MaskAfter = "*** (after)"
+)
+
+// SecretPaths holds the JSON pointer patterns of the Secret fields containing sensitive values,
+// including the last applied configuration recorded by kubectl.
+var SecretPaths = []string{
+	"/data/*",
+	"/stringData/*",
+	"/metadata/annotations/kubectl.kubernetes.io~1last-applied-configuration",
+}
+
+// MaskFields replaces the values of the fields matching the given patterns in both objects,
+// the masks only reveal if a field was added, removed or changed.
+func MaskFields(from, to map[string]interface{}, patterns []string) {
+	for _, pattern := range patterns {
+		pointers := make(map[string]bool)
+		for _, pointer := range Expand(from, pattern) {
+			pointers[pointer] = true
+		}
+		for _, pointer := range Expand(to, pattern) {
+			pointers[pointer] = true
+		}
+
+		for pointer := range pointers {
+			fromValue, inFrom := Get(from, pointer)
+			toValue, inTo := Get(to, pointer)
+			if inFrom && inTo && !reflect.DeepEqual(fromValue, toValue) {
+				Set(from, pointer, MaskBefore)
+				Set(to, pointer, MaskAfter)
+				continue
+			}
+			Set(from, pointer, Mask)
+			Set(to, pointer, Mask)
+		}
+	}
+}
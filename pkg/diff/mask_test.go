@@ -0,0 +1,82 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestMaskFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     map[string]interface{}
+		to       map[string]interface{}
+		patterns []string
+		wantFrom map[string]interface{}
+		wantTo   map[string]interface{}
+	}{
+		{
+			name:     "unchanged field",
+			from:     map[string]interface{}{"data": map[string]interface{}{"key": "secret"}},
+			to:       map[string]interface{}{"data": map[string]interface{}{"key": "secret"}},
+			patterns: []string{"/data/*"},
+			wantFrom: map[string]interface{}{"data": map[string]interface{}{"key": Mask}},
+			wantTo:   map[string]interface{}{"data": map[string]interface{}{"key": Mask}},
+		},
+		{
+			name:     "changed field",
+			from:     map[string]interface{}{"data": map[string]interface{}{"key": "old"}},
+			to:       map[string]interface{}{"data": map[string]interface{}{"key": "new"}},
+			patterns: []string{"/data/*"},
+			wantFrom: map[string]interface{}{"data": map[string]interface{}{"key": MaskBefore}},
+			wantTo:   map[string]interface{}{"data": map[string]interface{}{"key": MaskAfter}},
+		},
+		{
+			name:     "added and removed fields",
+			from:     map[string]interface{}{"data": map[string]interface{}{"removed": "old"}},
+			to:       map[string]interface{}{"data": map[string]interface{}{"added": "new"}},
+			patterns: []string{"/data/*"},
+			wantFrom: map[string]interface{}{"data": map[string]interface{}{"removed": Mask}},
+			wantTo:   map[string]interface{}{"data": map[string]interface{}{"added": Mask}},
+		},
+		{
+			name:     "missing object",
+			to:       map[string]interface{}{"data": map[string]interface{}{"key": "new"}},
+			patterns: []string{"/data/*"},
+			wantTo:   map[string]interface{}{"data": map[string]interface{}{"key": Mask}},
+		},
+		{
+			name:     "fields outside the patterns",
+			from:     map[string]interface{}{"data": map[string]interface{}{"key": "old"}, "kind": "Secret"},
+			to:       map[string]interface{}{"data": map[string]interface{}{"key": "old"}, "kind": "Secret"},
+			patterns: []string{"/stringData/*"},
+			wantFrom: map[string]interface{}{"data": map[string]interface{}{"key": "old"}, "kind": "Secret"},
+			wantTo:   map[string]interface{}{"data": map[string]interface{}{"key": "old"}, "kind": "Secret"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			MaskFields(tt.from, tt.to, tt.patterns)
+			g.Expect(tt.from).To(Equal(tt.wantFrom))
+			g.Expect(tt.to).To(Equal(tt.wantTo))
+		})
+	}
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Wildcard matches any map key or list index in a JSON pointer pattern.
+const Wildcard = "*"
+
+// ParsePointer splits a JSON pointer into its unescaped reference tokens.
+func ParsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer '%s', must start with '/'", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// Expand returns the JSON pointers of the fields present in the object that match the given pattern,
+// the pattern can contain '*' tokens matching any map key or list index.
+func Expand(object interface{}, pattern string) []string {
+	tokens, err := ParsePointer(pattern)
+	if err != nil {
+		return nil
+	}
+
+	var pointers []string
+	expand(object, "", tokens, &pointers)
+	sort.Strings(pointers)
+	return pointers
+}
+
+func expand(value interface{}, path string, tokens []string, pointers *[]string) {
+	if len(tokens) == 0 {
+		*pointers = append(*pointers, path)
+		return
+	}
+
+	token, rest := tokens[0], tokens[1:]
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if token == Wildcard {
+			for key, child := range v {
+				expand(child, path+"/"+EscapePointer(key), rest, pointers)
+			}
+			return
+		}
+		if child, ok := v[token]; ok {
+			expand(child, path+"/"+EscapePointer(token), rest, pointers)
+		}
+	case []interface{}:
+		if token == Wildcard {
+			for i, child := range v {
+				expand(child, path+"/"+strconv.Itoa(i), rest, pointers)
+			}
+			return
+		}
+		if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < len(v) {
+			expand(v[i], path+"/"+token, rest, pointers)
+		}
+	}
+}
+
+// Get returns the value of the field at the given JSON pointer.
+func Get(object interface{}, pointer string) (interface{}, bool) {
+	tokens, err := ParsePointer(pointer)
+	if err != nil {
+		return nil, false
+	}
+
+	value := object
+	for _, token := range tokens {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			child, ok := v[token]
+			if !ok {
+				return nil, false
+			}
+			value = child
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// Set replaces the value of an existing field at the given JSON pointer.
+// It returns false if the field doesn't exist.
+func Set(object interface{}, pointer string, value interface{}) bool {
+	return update(object, pointer, func(parent interface{}, token string) bool {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := p[token]; !ok {
+				return false
+			}
+			p[token] = value
+			return true
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(p) {
+				return false
+			}
+			p[i] = value
+			return true
+		}
+		return false
+	})
+}
+
+// update calls fn with the parent of the field at the given JSON pointer and the field token.
+func update(object interface{}, pointer string, fn func(parent interface{}, token string) bool) bool {
+	tokens, err := ParsePointer(pointer)
+	if err != nil || len(tokens) == 0 {
+		return false
+	}
+
+	parentPointer := ""
+	for _, token := range tokens[:len(tokens)-1] {
+		parentPointer += "/" + EscapePointer(token)
+	}
+
+	parent, ok := Get(object, parentPointer)
+	if !ok {
+		return false
+	}
+	return fn(parent, tokens[len(tokens)-1])
+}
+
+// Remove deletes the fields at the given JSON pointers, the list items are removed
+// starting with the highest index so that the pointers to the other items remain valid.
+func Remove(object interface{}, pointers ...string) {
+	unique := make(map[string]bool)
+	var sorted []string
+	for _, pointer := range pointers {
+		if !unique[pointer] {
+			unique[pointer] = true
+			sorted = append(sorted, pointer)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return comparePointers(sorted[i], sorted[j]) > 0
+	})
+
+	for _, pointer := range sorted {
+		tokens, err := ParsePointer(pointer)
+		if err != nil || len(tokens) == 0 {
+			continue
+		}
+		parentPointer := pointer[:len(pointer)-len(EscapePointer(tokens[len(tokens)-1]))-1]
+		update(object, pointer, func(parent interface{}, token string) bool {
+			switch p := parent.(type) {
+			case map[string]interface{}:
+				delete(p, token)
+				return true
+			case []interface{}:
+				i, err := strconv.Atoi(token)
+				if err != nil || i < 0 || i >= len(p) {
+					return false
+				}
+				items := append(p[:i:i], p[i+1:]...)
+				return Set(object, parentPointer, items)
+			}
+			return false
+		})
+	}
+}
+
+// comparePointers orders the JSON pointers token by token, list indexes are compared as numbers.
+func comparePointers(a, b string) int {
+	ta, _ := ParsePointer(a)
+	tb, _ := ParsePointer(b)
+	for i := 0; i < len(ta) && i < len(tb); i++ {
+		if ta[i] == tb[i] {
+			continue
+		}
+		ia, errA := strconv.Atoi(ta[i])
+		ib, errB := strconv.Atoi(tb[i])
+		if errA == nil && errB == nil {
+			if ia < ib {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(ta[i], tb[i])
+	}
+	return len(ta) - len(tb)
+}
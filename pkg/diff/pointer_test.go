@@ -0,0 +1,108 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestExpand(t *testing.T) {
+	object := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				"example.com/key": "value",
+			},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "app", "image": "app:v1"},
+				map[string]interface{}{"name": "sidecar"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    []string
+	}{
+		{name: "field", pattern: "/spec/containers/0/image", want: []string{"/spec/containers/0/image"}},
+		{name: "escaped key", pattern: "/metadata/annotations/example.com~1key", want: []string{"/metadata/annotations/example.com~1key"}},
+		{name: "list wildcard", pattern: "/spec/containers/*/name", want: []string{"/spec/containers/0/name", "/spec/containers/1/name"}},
+		{name: "skips missing fields", pattern: "/spec/containers/*/image", want: []string{"/spec/containers/0/image"}},
+		{name: "map wildcard", pattern: "/metadata/*/*", want: []string{"/metadata/annotations/example.com~1key"}},
+		{name: "missing field", pattern: "/status"},
+		{name: "invalid pointer", pattern: "spec"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(Expand(object, tt.pattern)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		name     string
+		object   map[string]interface{}
+		pointers []string
+		want     map[string]interface{}
+	}{
+		{
+			name:     "map fields",
+			object:   map[string]interface{}{"a": "1", "b": map[string]interface{}{"c": "2", "d": "3"}},
+			pointers: []string{"/a", "/b/c"},
+			want:     map[string]interface{}{"b": map[string]interface{}{"d": "3"}},
+		},
+		{
+			name:     "list items in any order",
+			object:   map[string]interface{}{"items": []interface{}{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}},
+			pointers: []string{"/items/2", "/items/10", "/items/9"},
+			want:     map[string]interface{}{"items": []interface{}{"0", "1", "3", "4", "5", "6", "7", "8"}},
+		},
+		{
+			name:     "duplicated pointers",
+			object:   map[string]interface{}{"items": []interface{}{"0", "1", "2"}},
+			pointers: []string{"/items/0", "/items/0"},
+			want:     map[string]interface{}{"items": []interface{}{"1", "2"}},
+		},
+		{
+			name:     "nested list items",
+			object:   map[string]interface{}{"items": []interface{}{map[string]interface{}{"ports": []interface{}{"80", "443"}}}},
+			pointers: []string{"/items/0/ports/0"},
+			want:     map[string]interface{}{"items": []interface{}{map[string]interface{}{"ports": []interface{}{"443"}}}},
+		},
+		{
+			name:     "missing fields",
+			object:   map[string]interface{}{"items": []interface{}{"0"}},
+			pointers: []string{"/spec", "/items/1", "/items/x", ""},
+			want:     map[string]interface{}{"items": []interface{}{"0"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			Remove(tt.object, tt.pointers...)
+			g.Expect(tt.object).To(Equal(tt.want))
+		})
+	}
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultContext is the number of unchanged lines printed around each change.
+const DefaultContext = 3
+
+type operation int
+
+const (
+	equalOp operation = iota
+	deleteOp
+	insertOp
+)
+
+type edit struct {
+	op   operation
+	line string
+}
+
+// Unified returns the unified diff of the two texts without the file headers,
+// an empty string is returned if the texts are equal.
+func Unified(from, to string, context int) string {
+	edits := diffLines(splitLines(from), splitLines(to))
+
+	var changed []int
+	for i, e := range edits {
+		if e.op != equalOp {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	for start := 0; start < len(changed); {
+		// extend the hunk while the next change is within two context windows
+		end := start
+		for end+1 < len(changed) && changed[end+1]-changed[end] <= 2*context {
+			end++
+		}
+
+		first := maxInt(0, changed[start]-context)
+		last := minInt(len(edits)-1, changed[end]+context)
+		writeHunk(&sb, edits, first, last)
+
+		start = end + 1
+	}
+	return sb.String()
+}
+
+func writeHunk(sb *strings.Builder, edits []edit, first, last int) {
+	// count the lines of both texts preceding the hunk
+	fromLine, toLine := 0, 0
+	for _, e := range edits[:first] {
+		if e.op != insertOp {
+			fromLine++
+		}
+		if e.op != deleteOp {
+			toLine++
+		}
+	}
+
+	fromCount, toCount := 0, 0
+	var body strings.Builder
+	for _, e := range edits[first : last+1] {
+		switch e.op {
+		case equalOp:
+			fromCount++
+			toCount++
+			body.WriteString(" " + e.line + "\n")
+		case deleteOp:
+			fromCount++
+			body.WriteString("-" + e.line + "\n")
+		case insertOp:
+			toCount++
+			body.WriteString("+" + e.line + "\n")
+		}
+	}
+
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(fromLine, fromCount), hunkRange(toLine, toCount))
+	sb.WriteString(body.String())
+}
+
+// hunkRange formats the start line and the line count of a hunk, an empty range starts at the preceding line.
+func hunkRange(line, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", line)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", line+1)
+	}
+	return fmt.Sprintf("%d,%d", line+1, count)
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines computes the shortest edit script between the two lists of lines with the linear space
+// variant of the Myers algorithm, the common prefix and suffix are matched before searching for changes.
+func diffLines(a, b []string) []edit {
+	return appendEdits(make([]edit, 0, len(a)+len(b)), a, b)
+}
+
+func appendEdits(edits []edit, a, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		edits = append(edits, edit{op: equalOp, line: a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			edits = append(edits, edit{op: insertOp, line: line})
+		}
+	case len(b) == 0:
+		for _, line := range a {
+			edits = append(edits, edit{op: deleteOp, line: line})
+		}
+	default:
+		// without a common prefix and suffix, at least two edits are needed and
+		// the middle snake splits the texts in two parts requiring fewer edits
+		x, y, u, v := middleSnake(a, b)
+		edits = appendEdits(edits, a[:x], b[:y])
+		for _, line := range a[x:u] {
+			edits = append(edits, edit{op: equalOp, line: line})
+		}
+		edits = appendEdits(edits, a[u:], b[v:])
+	}
+
+	for _, line := range common {
+		edits = append(edits, edit{op: equalOp, line: line})
+	}
+	return edits
+}
+
+// middleSnake searches forward from the start and backward from the end of the texts at the same time,
+// and returns the start (x, y) and the end (u, v) of the snake where the two searches meet.
+func middleSnake(a, b []string) (int, int, int, int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+
+	// the furthest x reached on each diagonal, the backward search runs on the reversed texts
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			if kb := delta - k; odd && kb >= -(d-1) && kb <= d-1 && x+backward[offset+kb] >= n {
+				return startX, startY, x, y
+			}
+		}
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			if kf := delta - k; !odd && kf >= -d && kf <= d && forward[offset+kf]+x >= n {
+				return n - x, m - y, n - startX, m - startY
+			}
+		}
+	}
+
+	// unreachable, the searches always meet within (n+m+1)/2 steps
+	return 0, 0, 0, 0
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
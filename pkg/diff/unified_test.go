@@ -0,0 +1,185 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		from  []string
+		to    []string
+		edits int
+	}{
+		{name: "equal", from: []string{"a", "b", "c"}, to: []string{"a", "b", "c"}, edits: 0},
+		{name: "both empty", edits: 0},
+		{name: "from empty", to: []string{"a", "b"}, edits: 2},
+		{name: "to empty", from: []string{"a", "b"}, edits: 2},
+		{name: "insert in the middle", from: []string{"a", "c"}, to: []string{"a", "b", "c"}, edits: 1},
+		{name: "delete at the end", from: []string{"a", "b", "c"}, to: []string{"a", "b"}, edits: 1},
+		{name: "replace all", from: []string{"a", "b"}, to: []string{"c", "d"}, edits: 4},
+		{name: "move line", from: []string{"a", "b", "c", "d"}, to: []string{"b", "c", "d", "a"}, edits: 2},
+		{name: "myers example", from: strings.Split("abcabba", ""), to: strings.Split("cbabac", ""), edits: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			edits := diffLines(tt.from, tt.to)
+			g.Expect(applyEdits(edits)).To(Equal([2][]string{tt.from, tt.to}))
+			g.Expect(countEdits(edits)).To(Equal(tt.edits))
+		})
+	}
+
+	t.Run("finds the shortest edit script", func(t *testing.T) {
+		g := NewWithT(t)
+		r := rand.New(rand.NewSource(1))
+		randomLines := func() []string {
+			var lines []string
+			for i := r.Intn(12); i > 0; i-- {
+				lines = append(lines, fmt.Sprintf("%c", 'a'+r.Intn(3)))
+			}
+			return lines
+		}
+
+		for i := 0; i < 1000; i++ {
+			from, to := randomLines(), randomLines()
+			edits := diffLines(from, to)
+			g.Expect(applyEdits(edits)).To(Equal([2][]string{from, to}))
+			g.Expect(countEdits(edits)).To(Equal(len(from)+len(to)-2*lcsLength(from, to)),
+				"from %v to %v", from, to)
+		}
+	})
+
+	t.Run("diffs large texts in linear space", func(t *testing.T) {
+		g := NewWithT(t)
+		lines := make([]string, 20000)
+		for i := range lines {
+			lines[i] = fmt.Sprintf("line %d", i)
+		}
+
+		edits := diffLines(nil, lines)
+		g.Expect(countEdits(edits)).To(Equal(len(lines)))
+
+		changed := append([]string(nil), lines...)
+		for i := 0; i < len(changed); i += 100 {
+			changed[i] = "changed"
+		}
+		edits = diffLines(lines, changed)
+		g.Expect(applyEdits(edits)).To(Equal([2][]string{lines, changed}))
+		g.Expect(countEdits(edits)).To(Equal(2 * len(lines) / 100))
+	})
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		context int
+		want    string
+	}{
+		{
+			name: "equal",
+			from: "a\nb\n",
+			to:   "a\nb\n",
+			want: "",
+		},
+		{
+			name:    "added",
+			to:      "a\nb\n",
+			context: DefaultContext,
+			want:    "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:    "removed",
+			from:    "a\n",
+			context: DefaultContext,
+			want:    "@@ -1 +0,0 @@\n-a\n",
+		},
+		{
+			name:    "changed with context",
+			from:    "a\nb\nc\nd\ne\n",
+			to:      "a\nb\nx\nd\ne\n",
+			context: 1,
+			want:    "@@ -2,3 +2,3 @@\n b\n-c\n+x\n d\n",
+		},
+		{
+			name:    "separate hunks",
+			from:    "a\nb\nc\nd\ne\nf\n",
+			to:      "x\nb\nc\nd\ne\ny\n",
+			context: 1,
+			want:    "@@ -1,2 +1,2 @@\n-a\n+x\n b\n@@ -5,2 +5,2 @@\n e\n-f\n+y\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(Unified(tt.from, tt.to, tt.context)).To(Equal(tt.want))
+		})
+	}
+}
+
+// applyEdits returns the texts before and after the edits.
+func applyEdits(edits []edit) [2][]string {
+	var texts [2][]string
+	for _, e := range edits {
+		if e.op != insertOp {
+			texts[0] = append(texts[0], e.line)
+		}
+		if e.op != deleteOp {
+			texts[1] = append(texts[1], e.line)
+		}
+	}
+	return texts
+}
+
+func countEdits(edits []edit) int {
+	count := 0
+	for _, e := range edits {
+		if e.op != equalOp {
+			count++
+		}
+	}
+	return count
+}
+
+// lcsLength returns the length of the longest common subsequence computed with dynamic programming.
+func lcsLength(a, b []string) int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = maxInt(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+	return lengths[0][0]
+}
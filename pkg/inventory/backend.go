@@ -0,0 +1,169 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	ConfigMapBackend = "ConfigMap"
+	SecretBackend    = "Secret"
+)
+
+// Backend is the Kubernetes kind used to persist the inventory data in-cluster.
+type Backend interface {
+	// Kind returns the Kubernetes kind of the storage objects.
+	Kind() string
+
+	// NewObject returns an empty storage object with the given name and namespace.
+	NewObject(name, namespace string) client.Object
+
+	// NewObjectList returns an empty list of storage objects.
+	NewObjectList() client.ObjectList
+
+	// ListItems returns the storage objects contained in the given list.
+	ListItems(list client.ObjectList) []client.Object
+
+	// GetData returns the data entries of the given storage object.
+	GetData(obj client.Object) map[string][]byte
+
+	// SetData replaces the data entries of the given storage object.
+	SetData(obj client.Object, data map[string][]byte)
+}
+
+// NewBackend returns the storage backend for the given Kubernetes kind.
+func NewBackend(kind string) (Backend, error) {
+	switch kind {
+	case ConfigMapBackend, "":
+		return &configMapBackend{}, nil
+	case SecretBackend:
+		return &secretBackend{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported inventory storage '%s', can be %s or %s", kind, ConfigMapBackend, SecretBackend)
+	}
+}
+
+// configMapBackend stores the inventory in a ConfigMap,
+// the compressed entries are saved as binary data.
+type configMapBackend struct{}
+
+func (b *configMapBackend) Kind() string {
+	return ConfigMapBackend
+}
+
+func (b *configMapBackend) NewObject(name, namespace string) client.Object {
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       ConfigMapBackend,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
+func (b *configMapBackend) NewObjectList() client.ObjectList {
+	return &corev1.ConfigMapList{}
+}
+
+func (b *configMapBackend) ListItems(list client.ObjectList) []client.Object {
+	var items []client.Object
+	for i := range list.(*corev1.ConfigMapList).Items {
+		items = append(items, &list.(*corev1.ConfigMapList).Items[i])
+	}
+	return items
+}
+
+func (b *configMapBackend) GetData(obj client.Object) map[string][]byte {
+	cm := obj.(*corev1.ConfigMap)
+	data := make(map[string][]byte)
+	for k, v := range cm.Data {
+		data[k] = []byte(v)
+	}
+	for k, v := range cm.BinaryData {
+		data[k] = v
+	}
+	return data
+}
+
+func (b *configMapBackend) SetData(obj client.Object, data map[string][]byte) {
+	cm := obj.(*corev1.ConfigMap)
+	cm.Data = nil
+	cm.BinaryData = nil
+	for k, v := range data {
+		if strings.HasSuffix(k, compressedSuffix) {
+			if cm.BinaryData == nil {
+				cm.BinaryData = make(map[string][]byte)
+			}
+			cm.BinaryData[k] = v
+			continue
+		}
+		if cm.Data == nil {
+			cm.Data = make(map[string]string)
+		}
+		cm.Data[k] = string(v)
+	}
+}
+
+// secretBackend stores the inventory in an Opaque Secret.
+type secretBackend struct{}
+
+func (b *secretBackend) Kind() string {
+	return SecretBackend
+}
+
+func (b *secretBackend) NewObject(name, namespace string) client.Object {
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       SecretBackend,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+}
+
+func (b *secretBackend) NewObjectList() client.ObjectList {
+	return &corev1.SecretList{}
+}
+
+func (b *secretBackend) ListItems(list client.ObjectList) []client.Object {
+	var items []client.Object
+	for i := range list.(*corev1.SecretList).Items {
+		items = append(items, &list.(*corev1.SecretList).Items[i])
+	}
+	return items
+}
+
+func (b *secretBackend) GetData(obj client.Object) map[string][]byte {
+	return obj.(*corev1.Secret).Data
+}
+
+func (b *secretBackend) SetData(obj client.Object, data map[string][]byte) {
+	obj.(*corev1.Secret).Data = data
+}
@@ -42,11 +42,22 @@ type Inventory struct {
 	// LastAppliedAt is the timestamp (UTC RFC3339) of the last successful apply.
 	LastAppliedAt string `json:"lastAppliedTime,omitempty"`
 
+	// Generation is the revision number of this inventory,
+	// it is incremented every time the inventory is applied.
+	Generation int64 `json:"generation,omitempty"`
+
+	// ResourceVersion is the version of the storage object this inventory was read from,
+	// when set, the inventory is written only if the storage object wasn't modified in the meantime.
+	ResourceVersion string `json:"-"`
+
 	// Resources is the list of Kubernetes object IDs.
 	Resources []Resource `json:"resources"`
 
 	// Artifacts is the list of the OCI URLs.
 	Artifacts []string `json:"artifacts"`
+
+	// DeleteHooks is the list of the Jobs run before the inventory objects are deleted.
+	DeleteHooks []*unstructured.Unstructured `json:"deleteHooks,omitempty"`
 }
 
 // Resource contains the information necessary to locate the Kubernetes object.
@@ -75,7 +86,13 @@ func (inv *Inventory) SetSource(url, revision string, artifacts []string) {
 }
 
 // AddObjects extracts the metadata from the given objects and adds it to the inventory.
+// The objects that are already present in the inventory are skipped.
 func (inv *Inventory) AddObjects(objects []*unstructured.Unstructured) error {
+	existing := make(map[string]bool, len(inv.Resources))
+	for _, entry := range inv.Resources {
+		existing[entry.ObjectID] = true
+	}
+
 	sort.Sort(ssa.SortableUnstructureds(objects))
 	for _, om := range objects {
 		objMetadata := object.UnstructuredToObjMetadata(om)
@@ -84,6 +101,11 @@ func (inv *Inventory) AddObjects(objects []*unstructured.Unstructured) error {
 			return err
 		}
 
+		if existing[objMetadata.String()] {
+			continue
+		}
+		existing[objMetadata.String()] = true
+
 		inv.Resources = append(inv.Resources, Resource{
 			ObjectID:      objMetadata.String(),
 			ObjectVersion: gv.Version,
@@ -0,0 +1,310 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultLockTTL is the duration after which a lock that is no longer renewed expires.
+	DefaultLockTTL    = time.Minute
+	lockRetryInterval = 2 * time.Second
+)
+
+// LockedError is returned when the inventory lease is held by a different identity.
+type LockedError struct {
+	Name      string
+	Namespace string
+	Holder    string
+	RenewTime time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("inventory %s/%s is locked by '%s' since %s",
+		e.Namespace, e.Name, e.Holder, e.RenewTime.UTC().Format(time.RFC3339))
+}
+
+// LockLostError is returned when the inventory lease was removed or taken over while the lock was held.
+type LockLostError struct {
+	Name      string
+	Namespace string
+	Err       error
+}
+
+func (e *LockLostError) Error() string {
+	return fmt.Sprintf("lock for inventory %s/%s was lost, error: %v", e.Namespace, e.Name, e.Err)
+}
+
+func (e *LockLostError) Unwrap() error {
+	return e.Err
+}
+
+// Lock is a Lease held on an inventory to prevent concurrent operations.
+// The lease is renewed in the background until the lock is released or lost.
+type Lock struct {
+	storage  *Storage
+	lease    *coordinationv1.Lease
+	identity string
+	ttl      time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	lost     chan struct{}
+	err      error
+}
+
+// LockInventory acquires the lease of the given inventory for the specified identity.
+// If the lease is held by a different identity, it retries until the timeout expires.
+func (s *Storage) LockInventory(ctx context.Context, i *Inventory, identity string, timeout time.Duration) (*Lock, error) {
+	ttl := s.LockTTL
+	if ttl == 0 {
+		ttl = DefaultLockTTL
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		lease, err := s.tryLock(ctx, i, identity, ttl)
+		if err == nil {
+			lock := &Lock{
+				storage:  s,
+				lease:    lease,
+				identity: identity,
+				ttl:      ttl,
+				stop:     make(chan struct{}),
+				done:     make(chan struct{}),
+				lost:     make(chan struct{}),
+			}
+			go lock.renew()
+			return lock, nil
+		}
+
+		var lockedErr *LockedError
+		if !errors.As(err, &lockedErr) && !apierrors.IsConflict(err) && !apierrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("failed to acquire lock for inventory %s/%s, error: %w", i.Namespace, i.Name, err)
+		}
+
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// UnlockInventory removes the lease of the given inventory regardless of its holder.
+// It returns the identity of the removed lease holder.
+func (s *Storage) UnlockInventory(ctx context.Context, i *Inventory) (string, error) {
+	lease := s.newLease(i.Name, i.Namespace)
+	if err := s.Manager.Client().Get(ctx, client.ObjectKeyFromObject(lease), lease); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", fmt.Errorf("inventory %s/%s is not locked", i.Namespace, i.Name)
+		}
+		return "", err
+	}
+
+	holder := ""
+	if lease.Spec.HolderIdentity != nil {
+		holder = *lease.Spec.HolderIdentity
+	}
+
+	if err := s.Manager.Client().Delete(ctx, lease); err != nil && !apierrors.IsNotFound(err) {
+		return "", err
+	}
+
+	return holder, nil
+}
+
+// Release stops renewing the lease and deletes it if still held by this lock.
+func (l *Lock) Release(ctx context.Context) error {
+	close(l.stop)
+	<-l.done
+
+	lease := l.lease.DeepCopy()
+	if err := l.storage.Manager.Client().Get(ctx, client.ObjectKeyFromObject(lease), lease); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != l.identity {
+		return nil
+	}
+
+	err := l.storage.Manager.Client().Delete(ctx, lease, client.Preconditions{ResourceVersion: &lease.ResourceVersion})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to release lock, error: %w", err)
+	}
+	return nil
+}
+
+// Lost returns a channel that is closed when the lease was removed or taken over by another operation.
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Err returns a LockLostError if the lease is no longer held by this lock.
+func (l *Lock) Err() error {
+	select {
+	case <-l.lost:
+		return l.err
+	default:
+		return nil
+	}
+}
+
+func (l *Lock) renew() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), l.ttl/3)
+			lease := l.lease.DeepCopy()
+			now := metav1.NowMicro()
+			lease.Spec.RenewTime = &now
+			err := l.storage.Manager.Client().Update(ctx, lease)
+			cancel()
+			if err == nil {
+				l.lease = lease
+				continue
+			}
+
+			if l.storage.LockErrorHandler != nil {
+				l.storage.LockErrorHandler(fmt.Errorf("failed to renew lock for inventory %s/%s, error: %w",
+					l.lease.Namespace, l.lease.Labels[nameLabelKey], err))
+			}
+
+			// the lease was deleted or updated by another operation, retrying won't get it back
+			if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+				l.err = &LockLostError{Name: l.lease.Labels[nameLabelKey], Namespace: l.lease.Namespace, Err: err}
+				close(l.lost)
+				return
+			}
+		}
+	}
+}
+
+// tryLock creates the lease or takes it over if it's held
+// by the same identity or if the previous holder failed to renew it.
+func (s *Storage) tryLock(ctx context.Context, i *Inventory, identity string, ttl time.Duration) (*coordinationv1.Lease, error) {
+	now := metav1.NowMicro()
+	lease := s.newLease(i.Name, i.Namespace)
+	err := s.Manager.Client().Get(ctx, client.ObjectKeyFromObject(lease), lease)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+
+		lease.Spec = coordinationv1.LeaseSpec{
+			HolderIdentity:       &identity,
+			LeaseDurationSeconds: leaseDuration(ttl),
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		}
+		if err := s.Manager.Client().Create(ctx, lease); err != nil {
+			return nil, err
+		}
+		return lease, nil
+	}
+
+	holder := ""
+	if lease.Spec.HolderIdentity != nil {
+		holder = *lease.Spec.HolderIdentity
+	}
+
+	if holder != "" && holder != identity && !isLeaseExpired(lease) {
+		lockedErr := &LockedError{
+			Name:      i.Name,
+			Namespace: i.Namespace,
+			Holder:    holder,
+		}
+		if lease.Spec.RenewTime != nil {
+			lockedErr.RenewTime = lease.Spec.RenewTime.Time
+		}
+		return nil, lockedErr
+	}
+
+	transitions := int32(0)
+	if lease.Spec.LeaseTransitions != nil {
+		transitions = *lease.Spec.LeaseTransitions
+	}
+	if holder != identity {
+		transitions++
+	}
+
+	lease.Spec = coordinationv1.LeaseSpec{
+		HolderIdentity:       &identity,
+		LeaseDurationSeconds: leaseDuration(ttl),
+		AcquireTime:          &now,
+		RenewTime:            &now,
+		LeaseTransitions:     &transitions,
+	}
+
+	// the update fails with a conflict if the lease was modified since we've read it
+	if err := s.Manager.Client().Update(ctx, lease); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+func (s *Storage) newLease(name, namespace string) *coordinationv1.Lease {
+	return &coordinationv1.Lease{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: coordinationv1.SchemeGroupVersion.String(),
+			Kind:       "Lease",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      storagePrefix + name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				nameLabelKey:      name,
+				componentLabelKey: KindName,
+				createdByLabelKey: s.Owner.Field,
+			},
+		},
+	}
+}
+
+func isLeaseExpired(lease *coordinationv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	ttl := time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	return lease.Spec.RenewTime.Add(ttl).Before(time.Now())
+}
+
+func leaseDuration(ttl time.Duration) *int32 {
+	seconds := int32(ttl.Seconds())
+	return &seconds
+}
@@ -17,8 +17,13 @@ limitations under the License.
 package inventory
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
+	"io"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -33,129 +38,293 @@ import (
 
 const (
 	KindName          = "inventory"
+	HistoryKindName   = "inventory-revision"
 	storagePrefix     = "inv-"
 	nameLabelKey      = "app.kubernetes.io/name"
 	componentLabelKey = "app.kubernetes.io/component"
 	createdByLabelKey = "app.kubernetes.io/created-by"
+	resourcesKey      = "resources"
+	artifactsKey      = "artifacts"
+	deleteHooksKey    = "delete-hooks"
+	compressedSuffix  = ".gz"
 )
 
+// ConflictError is returned when the stored inventory was modified
+// after it was read and before the new inventory was applied.
+type ConflictError struct {
+	Name      string
+	Namespace string
+	Err       error
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("inventory %s/%s has been modified by another operation", e.Namespace, e.Name)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
 // Storage manages the Inventory in-cluster storage.
 type Storage struct {
 	Manager *ssa.ResourceManager
 	Owner   ssa.Owner
+
+	// HistoryLimit is the maximum number of revisions retained for an inventory,
+	// when set to zero the revision history is disabled.
+	HistoryLimit int
+
+	// Backend is the Kubernetes kind used to persist the inventory,
+	// defaults to ConfigMap.
+	Backend Backend
+
+	// Compression enables gzip compression of the inventory entries.
+	Compression bool
+
+	// LockTTL is the lease duration of the inventory locks,
+	// defaults to DefaultLockTTL.
+	LockTTL time.Duration
+
+	// LockErrorHandler is called when an inventory lock fails to renew its lease.
+	LockErrorHandler func(err error)
+}
+
+// Kind returns the Kubernetes kind of the storage objects.
+func (s *Storage) Kind() string {
+	return s.backend().Kind()
 }
 
 // ApplyInventory creates or updates the storage object for the given inventory.
+// The inventory generation is incremented and a copy of the inventory is saved
+// in the revision history, retaining at most HistoryLimit revisions.
 func (s *Storage) ApplyInventory(ctx context.Context, i *Inventory, createNamespace bool) error {
-	resources, err := json.Marshal(i.Resources)
-	if err != nil {
-		return err
-	}
-
 	if createNamespace {
-		if err := s.createNamespace(ctx, i.Namespace); err != nil {
+		if err := s.CreateNamespace(ctx, i.Namespace); err != nil {
 			return err
 		}
 	}
 
-	cm := s.newConfigMap(i.Name, i.Namespace)
-	cm.Annotations = s.metaToAnnotations(i)
+	existingInventory := NewInventory(i.Name, i.Namespace)
+	if err := s.GetInventory(ctx, existingInventory); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
 
-	cm.Data = map[string]string{
-		"resources": string(resources),
+	if i.ResourceVersion != "" && i.ResourceVersion != existingInventory.ResourceVersion {
+		return &ConflictError{Name: i.Name, Namespace: i.Namespace}
 	}
 
-	if len(i.Artifacts) > 0 {
-		artifacts, err := json.Marshal(i.Artifacts)
-		if err != nil {
-			return err
+	i.Generation = existingInventory.Generation + 1
+	i.LastAppliedAt = time.Now().UTC().Format(time.RFC3339)
+
+	obj := s.newObject(i.Name, i.Namespace)
+	if err := s.inventoryToObject(i, obj); err != nil {
+		return err
+	}
+
+	// the storage object is created if it doesn't exist in the configured backend, otherwise it's replaced,
+	// both fail if the object was created or modified by another operation since the inventory was read
+	var err error
+	if existingInventory.ResourceVersion == "" {
+		err = s.Manager.Client().Create(ctx, obj, client.FieldOwner(s.Owner.Field))
+	} else {
+		obj.SetResourceVersion(existingInventory.ResourceVersion)
+		err = s.Manager.Client().Update(ctx, obj, client.FieldOwner(s.Owner.Field))
+	}
+	if err != nil {
+		if apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) {
+			return &ConflictError{Name: i.Name, Namespace: i.Namespace, Err: err}
 		}
-		cm.Data["artifacts"] = string(artifacts)
+		return err
+	}
+	i.ResourceVersion = obj.GetResourceVersion()
+
+	if err := s.migrateLegacyObjects(ctx, i); err != nil {
+		return err
+	}
+
+	if s.HistoryLimit < 1 {
+		return nil
+	}
+
+	revision := s.newRevisionObject(i.Name, i.Namespace, i.Generation)
+	if err := s.inventoryToObject(i, revision); err != nil {
+		return err
 	}
 
 	opts := []client.PatchOption{
 		client.ForceOwnership,
 		client.FieldOwner(s.Owner.Field),
 	}
-	return s.Manager.Client().Patch(ctx, cm, client.Apply, opts...)
+	if err := s.Manager.Client().Patch(ctx, revision, client.Apply, opts...); err != nil {
+		return fmt.Errorf("failed to save revision %d, error: %w", i.Generation, err)
+	}
+
+	return s.pruneHistory(ctx, i)
 }
 
 // GetInventory retrieves the entries from the storage for the given inventory name and namespace.
+// If the inventory is not found in the configured backend, the ConfigMap storage is used as fallback.
 func (s *Storage) GetInventory(ctx context.Context, i *Inventory) error {
-	cm := s.newConfigMap(i.Name, i.Namespace)
+	obj := s.newObject(i.Name, i.Namespace)
 
-	cmKey := client.ObjectKeyFromObject(cm)
-	err := s.Manager.Client().Get(ctx, cmKey, cm)
+	err := s.Manager.Client().Get(ctx, client.ObjectKeyFromObject(obj), obj)
 	if err != nil {
-		return err
+		legacy := s.legacyBackend()
+		if !apierrors.IsNotFound(err) || legacy == nil {
+			return err
+		}
+
+		obj = s.newObjectFor(legacy, i.Name, i.Namespace)
+		if err := s.Manager.Client().Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			return err
+		}
+		return s.inventoryFromObject(i, legacy, obj)
 	}
 
-	s.metaFromAnnotations(i, cm.GetAnnotations())
+	if err := s.inventoryFromObject(i, s.backend(), obj); err != nil {
+		return err
+	}
+	i.ResourceVersion = obj.GetResourceVersion()
+	return nil
+}
 
-	if _, ok := cm.Data["resources"]; !ok {
-		return fmt.Errorf("inventory data not found in ConfigMap/%s", cmKey)
+// GetInventoryRevision retrieves the entries from the revision history
+// for the given inventory name, namespace and generation.
+// If the revision is not found in the configured backend, the ConfigMap storage is used as fallback.
+func (s *Storage) GetInventoryRevision(ctx context.Context, i *Inventory, generation int64) error {
+	backend := s.backend()
+	obj := s.newRevisionObjectFor(backend, i.Name, i.Namespace, generation)
+
+	err := s.Manager.Client().Get(ctx, client.ObjectKeyFromObject(obj), obj)
+	if err != nil && apierrors.IsNotFound(err) {
+		if legacy := s.legacyBackend(); legacy != nil {
+			backend = legacy
+			obj = s.newRevisionObjectFor(backend, i.Name, i.Namespace, generation)
+			err = s.Manager.Client().Get(ctx, client.ObjectKeyFromObject(obj), obj)
+		}
 	}
-	var entries []Resource
-	err = json.Unmarshal([]byte(cm.Data["resources"]), &entries)
 	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("revision %d not found for inventory %s/%s", generation, i.Namespace, i.Name)
+		}
 		return err
 	}
-	i.Resources = entries
 
-	if artifacts, ok := cm.Data["artifacts"]; ok {
-		var list []string
-		err = json.Unmarshal([]byte(artifacts), &list)
-		if err != nil {
-			return err
+	return s.inventoryFromObject(i, backend, obj)
+}
+
+// GetInventoryHistory returns the saved revisions of the given inventory
+// sorted by generation in descending order.
+func (s *Storage) GetInventoryHistory(ctx context.Context, i *Inventory) ([]*Inventory, error) {
+	var revisions []*Inventory
+	list := s.backend().NewObjectList()
+	err := s.Manager.Client().List(ctx, list, client.InNamespace(i.Namespace), s.getRevisionLabels(i.Name))
+	if err != nil {
+		return revisions, err
+	}
+
+	for _, obj := range s.backend().ListItems(list) {
+		rev := NewInventory(i.Name, i.Namespace)
+		if err := s.inventoryFromObject(rev, s.backend(), obj); err != nil {
+			return revisions, err
 		}
-		i.Artifacts = list
+		revisions = append(revisions, rev)
 	}
 
-	return nil
+	// include the revisions that were not yet migrated from ConfigMaps
+	legacyRevisions, err := s.getLegacyHistory(ctx, i)
+	if err != nil {
+		return revisions, err
+	}
+	for _, rev := range legacyRevisions {
+		if !containsRevision(revisions, rev) {
+			revisions = append(revisions, rev)
+		}
+	}
+
+	sort.Slice(revisions, func(a, b int) bool {
+		return revisions[a].Generation > revisions[b].Generation
+	})
+
+	return revisions, nil
 }
 
 // ListInventories returns the inventories in the given namespace.
 func (s *Storage) ListInventories(ctx context.Context, namespace string) ([]*Inventory, error) {
 	var inventories []*Inventory
-	cmList := &corev1.ConfigMapList{}
-	err := s.Manager.Client().List(ctx, cmList, client.InNamespace(namespace), s.getOwnerLabels())
+	list := s.backend().NewObjectList()
+	err := s.Manager.Client().List(ctx, list, client.InNamespace(namespace), s.getOwnerLabels())
 	if err != nil {
 		return inventories, err
 	}
 
-	for _, cm := range cmList.Items {
-		i := NewInventory(strings.TrimPrefix(cm.GetName(), storagePrefix), cm.GetNamespace())
-		if err := s.GetInventory(ctx, i); err != nil {
+	for _, obj := range s.backend().ListItems(list) {
+		i := NewInventory(strings.TrimPrefix(obj.GetName(), storagePrefix), obj.GetNamespace())
+		if err := s.inventoryFromObject(i, s.backend(), obj); err != nil {
 			return inventories, err
 		}
 		inventories = append(inventories, i)
 	}
 
+	// include the inventories that were not yet migrated from ConfigMaps
+	if legacy := s.legacyBackend(); legacy != nil {
+		list := legacy.NewObjectList()
+		err := s.Manager.Client().List(ctx, list, client.InNamespace(namespace), s.getOwnerLabels())
+		if err != nil {
+			return inventories, err
+		}
+
+		for _, obj := range legacy.ListItems(list) {
+			i := NewInventory(strings.TrimPrefix(obj.GetName(), storagePrefix), obj.GetNamespace())
+			if containsInventory(inventories, i) {
+				continue
+			}
+			if err := s.inventoryFromObject(i, legacy, obj); err != nil {
+				return inventories, err
+			}
+			inventories = append(inventories, i)
+		}
+	}
+
 	return inventories, nil
 }
 
 // DeleteInventory removes the storage for the given inventory name and namespace.
 func (s *Storage) DeleteInventory(ctx context.Context, i *Inventory) error {
-	cm := s.newConfigMap(i.Name, i.Namespace)
+	obj := s.newObject(i.Name, i.Namespace)
 
-	cmKey := client.ObjectKeyFromObject(cm)
-	err := s.Manager.Client().Delete(ctx, cm)
+	key := client.ObjectKeyFromObject(obj)
+	err := s.Manager.Client().Delete(ctx, obj)
 	if err != nil && !apierrors.IsNotFound(err) {
-		return fmt.Errorf("failed to delete ConfigMap/%s, error: %w", cmKey, err)
+		return fmt.Errorf("failed to delete %s/%s, error: %w", s.Kind(), key, err)
+	}
+
+	if err := s.deleteLegacyObjects(ctx, i); err != nil {
+		return err
+	}
+
+	err = s.Manager.Client().DeleteAllOf(ctx, s.backend().NewObject("", ""), client.InNamespace(i.Namespace), s.getRevisionLabels(i.Name))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete the revision history of %s/%s, error: %w", s.Kind(), key, err)
 	}
 	return nil
 }
 
 // GetInventoryStaleObjects returns the list of objects metadata subject to pruning.
+// The resource version of the stored inventory is recorded in the given inventory,
+// so that ApplyInventory fails with a ConflictError if the stored inventory is modified
+// before the given one is applied.
 func (s *Storage) GetInventoryStaleObjects(ctx context.Context, i *Inventory) ([]*unstructured.Unstructured, error) {
 	objects := make([]*unstructured.Unstructured, 0)
 	existingInventory := NewInventory(i.Name, i.Namespace)
 	if err := s.GetInventory(ctx, existingInventory); err != nil {
 		if apierrors.IsNotFound(err) {
+			i.ResourceVersion = ""
 			return objects, nil
 		}
 		return nil, err
 	}
+	i.ResourceVersion = existingInventory.ResourceVersion
 
 	objects, err := existingInventory.Diff(i)
 	if err != nil {
@@ -165,6 +334,92 @@ func (s *Storage) GetInventoryStaleObjects(ctx context.Context, i *Inventory) ([
 	return objects, nil
 }
 
+func (s *Storage) backend() Backend {
+	if s.Backend == nil {
+		return &configMapBackend{}
+	}
+	return s.Backend
+}
+
+// legacyBackend returns the ConfigMap backend if a different backend is configured.
+func (s *Storage) legacyBackend() Backend {
+	if s.backend().Kind() == ConfigMapBackend {
+		return nil
+	}
+	return &configMapBackend{}
+}
+
+// getLegacyHistory returns the revisions of the given inventory saved in the ConfigMap storage,
+// before migrating to a different backend.
+func (s *Storage) getLegacyHistory(ctx context.Context, i *Inventory) ([]*Inventory, error) {
+	var revisions []*Inventory
+	legacy := s.legacyBackend()
+	if legacy == nil {
+		return revisions, nil
+	}
+
+	list := legacy.NewObjectList()
+	err := s.Manager.Client().List(ctx, list, client.InNamespace(i.Namespace), s.getRevisionLabels(i.Name))
+	if err != nil {
+		return revisions, err
+	}
+
+	for _, obj := range legacy.ListItems(list) {
+		rev := NewInventory(i.Name, i.Namespace)
+		if err := s.inventoryFromObject(rev, legacy, obj); err != nil {
+			return revisions, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, nil
+}
+
+// migrateLegacyObjects copies the inventory revisions from the ConfigMap storage to the configured backend,
+// then removes the inventory ConfigMap and its revisions. When the history is disabled,
+// the legacy revisions are removed without being copied.
+func (s *Storage) migrateLegacyObjects(ctx context.Context, i *Inventory) error {
+	revisions, err := s.getLegacyHistory(ctx, i)
+	if err != nil {
+		return err
+	}
+
+	if s.HistoryLimit > 0 {
+		for _, rev := range revisions {
+			obj := s.newRevisionObject(rev.Name, rev.Namespace, rev.Generation)
+			if err := s.inventoryToObject(rev, obj); err != nil {
+				return err
+			}
+			err := s.Manager.Client().Create(ctx, obj, client.FieldOwner(s.Owner.Field))
+			if err != nil && !apierrors.IsAlreadyExists(err) {
+				return fmt.Errorf("failed to migrate revision %d, error: %w", rev.Generation, err)
+			}
+		}
+	}
+
+	return s.deleteLegacyObjects(ctx, i)
+}
+
+// deleteLegacyObjects removes the inventory ConfigMap and its revisions after migrating to a different backend.
+func (s *Storage) deleteLegacyObjects(ctx context.Context, i *Inventory) error {
+	legacy := s.legacyBackend()
+	if legacy == nil {
+		return nil
+	}
+
+	obj := s.newObjectFor(legacy, i.Name, i.Namespace)
+	err := s.Manager.Client().Delete(ctx, obj)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete %s/%s, error: %w", legacy.Kind(), client.ObjectKeyFromObject(obj), err)
+	}
+
+	err = s.Manager.Client().DeleteAllOf(ctx, legacy.NewObject("", ""), client.InNamespace(i.Namespace), s.getRevisionLabels(i.Name))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete the revision history of %s/%s, error: %w",
+			legacy.Kind(), client.ObjectKeyFromObject(obj), err)
+	}
+	return nil
+}
+
 func (s *Storage) getOwnerLabels() client.MatchingLabels {
 	return client.MatchingLabels{
 		componentLabelKey: KindName,
@@ -172,9 +427,143 @@ func (s *Storage) getOwnerLabels() client.MatchingLabels {
 	}
 }
 
+func (s *Storage) getRevisionLabels(name string) client.MatchingLabels {
+	return client.MatchingLabels{
+		nameLabelKey:      name,
+		componentLabelKey: HistoryKindName,
+		createdByLabelKey: s.Owner.Field,
+	}
+}
+
+// pruneHistory deletes the revisions that exceed the history limit.
+func (s *Storage) pruneHistory(ctx context.Context, i *Inventory) error {
+	revisions, err := s.GetInventoryHistory(ctx, i)
+	if err != nil {
+		return err
+	}
+
+	for index, rev := range revisions {
+		if index < s.HistoryLimit {
+			continue
+		}
+		obj := s.newRevisionObject(rev.Name, rev.Namespace, rev.Generation)
+		if err := s.Manager.Client().Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete %s/%s, error: %w", s.Kind(), client.ObjectKeyFromObject(obj), err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) inventoryToObject(i *Inventory, obj client.Object) error {
+	resources, err := json.Marshal(i.Resources)
+	if err != nil {
+		return err
+	}
+
+	data := make(map[string][]byte)
+	if s.Compression {
+		compressed, err := compress(resources)
+		if err != nil {
+			return err
+		}
+		data[resourcesKey+compressedSuffix] = compressed
+	} else {
+		data[resourcesKey] = resources
+	}
+
+	if len(i.Artifacts) > 0 {
+		artifacts, err := json.Marshal(i.Artifacts)
+		if err != nil {
+			return err
+		}
+		data[artifactsKey] = artifacts
+	}
+
+	if len(i.DeleteHooks) > 0 {
+		hooks, err := json.Marshal(i.DeleteHooks)
+		if err != nil {
+			return err
+		}
+		if s.Compression {
+			compressed, err := compress(hooks)
+			if err != nil {
+				return err
+			}
+			data[deleteHooksKey+compressedSuffix] = compressed
+		} else {
+			data[deleteHooksKey] = hooks
+		}
+	}
+
+	obj.SetAnnotations(s.metaToAnnotations(i))
+	s.backend().SetData(obj, data)
+	return nil
+}
+
+func (s *Storage) inventoryFromObject(i *Inventory, backend Backend, obj client.Object) error {
+	s.metaFromAnnotations(i, obj.GetAnnotations())
+
+	data := backend.GetData(obj)
+	resources, ok := data[resourcesKey]
+	if compressed, found := data[resourcesKey+compressedSuffix]; found {
+		r, err := decompress(compressed)
+		if err != nil {
+			return fmt.Errorf("failed to decompress the inventory data in %s/%s, error: %w",
+				backend.Kind(), client.ObjectKeyFromObject(obj), err)
+		}
+		resources = r
+		ok = true
+	}
+	if !ok {
+		return fmt.Errorf("inventory data not found in %s/%s", backend.Kind(), client.ObjectKeyFromObject(obj))
+	}
+
+	var entries []Resource
+	err := json.Unmarshal(resources, &entries)
+	if err != nil {
+		return err
+	}
+	i.Resources = entries
+
+	if artifacts, ok := data[artifactsKey]; ok {
+		var list []string
+		err = json.Unmarshal(artifacts, &list)
+		if err != nil {
+			return err
+		}
+		i.Artifacts = list
+	}
+
+	hooks, ok := data[deleteHooksKey]
+	if compressed, found := data[deleteHooksKey+compressedSuffix]; found {
+		h, err := decompress(compressed)
+		if err != nil {
+			return fmt.Errorf("failed to decompress the inventory hooks in %s/%s, error: %w",
+				backend.Kind(), client.ObjectKeyFromObject(obj), err)
+		}
+		hooks = h
+		ok = true
+	}
+	if ok {
+		var list []*unstructured.Unstructured
+		err = json.Unmarshal(hooks, &list)
+		if err != nil {
+			return err
+		}
+		i.DeleteHooks = list
+	}
+
+	return nil
+}
+
 func (s *Storage) metaToAnnotations(inv *Inventory) map[string]string {
+	lastAppliedAt := inv.LastAppliedAt
+	if lastAppliedAt == "" {
+		lastAppliedAt = time.Now().UTC().Format(time.RFC3339)
+	}
 	annotations := map[string]string{
-		s.Owner.Group + "/last-applied-time": time.Now().UTC().Format(time.RFC3339),
+		s.Owner.Group + "/last-applied-time": lastAppliedAt,
 	}
 	if inv.Source != "" {
 		annotations[s.Owner.Group+"/source"] = inv.Source
@@ -182,6 +571,9 @@ func (s *Storage) metaToAnnotations(inv *Inventory) map[string]string {
 	if inv.Revision != "" {
 		annotations[s.Owner.Group+"/revision"] = inv.Revision
 	}
+	if inv.Generation > 0 {
+		annotations[s.Owner.Group+"/generation"] = strconv.FormatInt(inv.Generation, 10)
+	}
 
 	return annotations
 }
@@ -195,30 +587,43 @@ func (s *Storage) metaFromAnnotations(inv *Inventory, annotations map[string]str
 			inv.Revision = v
 		case s.Owner.Group + "/last-applied-time":
 			inv.LastAppliedAt = v
+		case s.Owner.Group + "/generation":
+			if generation, err := strconv.ParseInt(v, 10, 64); err == nil {
+				inv.Generation = generation
+			}
 		}
 	}
 }
 
-func (s *Storage) newConfigMap(name, namespace string) *corev1.ConfigMap {
-	return &corev1.ConfigMap{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "v1",
-			Kind:       "ConfigMap",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      storagePrefix + name,
-			Namespace: namespace,
-			Labels: map[string]string{
-				nameLabelKey:      name,
-				componentLabelKey: KindName,
-				createdByLabelKey: s.Owner.Field,
-			},
-		},
-	}
+func (s *Storage) newObject(name, namespace string) client.Object {
+	return s.newObjectFor(s.backend(), name, namespace)
+}
+
+func (s *Storage) newObjectFor(backend Backend, name, namespace string) client.Object {
+	obj := backend.NewObject(storagePrefix+name, namespace)
+	obj.SetLabels(map[string]string{
+		nameLabelKey:      name,
+		componentLabelKey: KindName,
+		createdByLabelKey: s.Owner.Field,
+	})
+	return obj
+}
+
+func (s *Storage) newRevisionObject(name, namespace string, generation int64) client.Object {
+	return s.newRevisionObjectFor(s.backend(), name, namespace, generation)
 }
 
-// createNamespace creates the inventory namespace if not present.
-func (s *Storage) createNamespace(ctx context.Context, name string) error {
+func (s *Storage) newRevisionObjectFor(backend Backend, name, namespace string, generation int64) client.Object {
+	obj := s.newObjectFor(backend, name, namespace)
+	obj.SetName(fmt.Sprintf("%s.v%d", obj.GetName(), generation))
+	labels := obj.GetLabels()
+	labels[componentLabelKey] = HistoryKindName
+	obj.SetLabels(labels)
+	return obj
+}
+
+// CreateNamespace creates the inventory namespace if not present.
+func (s *Storage) CreateNamespace(ctx context.Context, name string) error {
 	ns := &corev1.Namespace{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
@@ -246,3 +651,42 @@ func (s *Storage) createNamespace(ctx context.Context, name string) error {
 
 	return nil
 }
+
+func containsInventory(inventories []*Inventory, i *Inventory) bool {
+	for _, inv := range inventories {
+		if inv.Name == i.Name && inv.Namespace == i.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func containsRevision(revisions []*Inventory, rev *Inventory) bool {
+	for _, r := range revisions {
+		if r.Generation == rev.Generation {
+			return true
+		}
+	}
+	return false
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/fluxcd/pkg/ssa"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/gomega"
+)
+
+var testOwner = ssa.Owner{Field: "kustomizer", Group: "inventory.kustomizer.dev"}
+
+func newTestStorage(kubeClient client.Client, backend Backend) *Storage {
+	return &Storage{
+		Manager: ssa.NewResourceManager(kubeClient, nil, testOwner),
+		Owner:   testOwner,
+		Backend: backend,
+	}
+}
+
+// racingClient creates the object requested by the first Get that returns not found,
+// as if another operation wrote it in the meantime.
+type racingClient struct {
+	client.Client
+	raced bool
+}
+
+func (c *racingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	err := c.Client.Get(ctx, key, obj, opts...)
+	if apierrors.IsNotFound(err) && !c.raced {
+		c.raced = true
+		other := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+		if err := c.Client.Create(ctx, other); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func TestApplyInventory(t *testing.T) {
+	t.Run("creates and updates the inventory", func(t *testing.T) {
+		g := NewWithT(t)
+		storage := newTestStorage(fake.NewClientBuilder().Build(), &configMapBackend{})
+
+		inv := NewInventory("app", "default")
+		inv.Artifacts = []string{"localhost/app@sha256:1"}
+		g.Expect(storage.ApplyInventory(context.Background(), inv, false)).To(Succeed())
+		g.Expect(inv.Generation).To(Equal(int64(1)))
+
+		next := NewInventory("app", "default")
+		g.Expect(storage.ApplyInventory(context.Background(), next, false)).To(Succeed())
+		g.Expect(next.Generation).To(Equal(int64(2)))
+
+		stored := NewInventory("app", "default")
+		g.Expect(storage.GetInventory(context.Background(), stored)).To(Succeed())
+		g.Expect(stored.Generation).To(Equal(int64(2)))
+		g.Expect(stored.Artifacts).To(BeEmpty())
+	})
+
+	t.Run("fails to create an inventory created by another operation", func(t *testing.T) {
+		g := NewWithT(t)
+		storage := newTestStorage(&racingClient{Client: fake.NewClientBuilder().Build()}, &configMapBackend{})
+
+		err := storage.ApplyInventory(context.Background(), NewInventory("app", "default"), false)
+		var conflictErr *ConflictError
+		g.Expect(errors.As(err, &conflictErr)).To(BeTrue())
+	})
+
+	t.Run("fails to write a stale inventory", func(t *testing.T) {
+		g := NewWithT(t)
+		storage := newTestStorage(fake.NewClientBuilder().Build(), &configMapBackend{})
+		g.Expect(storage.ApplyInventory(context.Background(), NewInventory("app", "default"), false)).To(Succeed())
+
+		stale := NewInventory("app", "default")
+		g.Expect(storage.GetInventory(context.Background(), stale)).To(Succeed())
+		g.Expect(storage.ApplyInventory(context.Background(), NewInventory("app", "default"), false)).To(Succeed())
+
+		err := storage.ApplyInventory(context.Background(), stale, false)
+		var conflictErr *ConflictError
+		g.Expect(errors.As(err, &conflictErr)).To(BeTrue())
+	})
+}
+
+// createLegacyInventory writes the inventory and its revisions to the ConfigMap storage.
+func createLegacyInventory(g *WithT, kubeClient client.Client, generations ...int64) {
+	legacy := newTestStorage(kubeClient, &configMapBackend{})
+	for _, generation := range generations {
+		inv := NewInventory("app", "default")
+		inv.Generation = generation
+		inv.Resources = []Resource{{ObjectID: "default_app__ConfigMap", ObjectVersion: "v1"}}
+
+		obj := legacy.newRevisionObject(inv.Name, inv.Namespace, generation)
+		g.Expect(legacy.inventoryToObject(inv, obj)).To(Succeed())
+		g.Expect(kubeClient.Create(context.Background(), obj)).To(Succeed())
+
+		obj = legacy.newObject(inv.Name, inv.Namespace)
+		g.Expect(legacy.inventoryToObject(inv, obj)).To(Succeed())
+		g.Expect(client.IgnoreNotFound(kubeClient.Delete(context.Background(), obj))).To(Succeed())
+		g.Expect(kubeClient.Create(context.Background(), obj)).To(Succeed())
+	}
+}
+
+func TestLegacyStorage(t *testing.T) {
+	t.Run("reads the inventory and its revisions from ConfigMaps", func(t *testing.T) {
+		g := NewWithT(t)
+		kubeClient := fake.NewClientBuilder().Build()
+		createLegacyInventory(g, kubeClient, 1, 2)
+		storage := newTestStorage(kubeClient, &secretBackend{})
+
+		inv := NewInventory("app", "default")
+		g.Expect(storage.GetInventory(context.Background(), inv)).To(Succeed())
+		g.Expect(inv.Generation).To(Equal(int64(2)))
+		g.Expect(inv.Resources).To(HaveLen(1))
+		g.Expect(inv.ResourceVersion).To(BeEmpty())
+
+		revisions, err := storage.GetInventoryHistory(context.Background(), inv)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(revisions).To(HaveLen(2))
+		g.Expect(revisions[0].Generation).To(Equal(int64(2)))
+
+		rev := NewInventory("app", "default")
+		g.Expect(storage.GetInventoryRevision(context.Background(), rev, 1)).To(Succeed())
+		g.Expect(rev.Resources).To(HaveLen(1))
+	})
+
+	t.Run("migrates the revisions to the configured backend", func(t *testing.T) {
+		g := NewWithT(t)
+		kubeClient := fake.NewClientBuilder().Build()
+		createLegacyInventory(g, kubeClient, 1, 2)
+		storage := newTestStorage(kubeClient, &secretBackend{})
+		storage.HistoryLimit = 10
+
+		inv := NewInventory("app", "default")
+		g.Expect(storage.migrateLegacyObjects(context.Background(), inv)).To(Succeed())
+
+		configMaps := &corev1.ConfigMapList{}
+		g.Expect(kubeClient.List(context.Background(), configMaps)).To(Succeed())
+		g.Expect(configMaps.Items).To(BeEmpty())
+
+		secrets := &corev1.SecretList{}
+		g.Expect(kubeClient.List(context.Background(), secrets, storage.getRevisionLabels(inv.Name))).To(Succeed())
+		g.Expect(secrets.Items).To(HaveLen(2))
+
+		rev := NewInventory("app", "default")
+		g.Expect(storage.GetInventoryRevision(context.Background(), rev, 2)).To(Succeed())
+		g.Expect(rev.Generation).To(Equal(int64(2)))
+		g.Expect(rev.Resources).To(HaveLen(1))
+	})
+
+	t.Run("removes the ConfigMaps on the first write", func(t *testing.T) {
+		g := NewWithT(t)
+		kubeClient := fake.NewClientBuilder().Build()
+		createLegacyInventory(g, kubeClient, 1)
+		storage := newTestStorage(kubeClient, &secretBackend{})
+
+		inv := NewInventory("app", "default")
+		g.Expect(storage.ApplyInventory(context.Background(), inv, false)).To(Succeed())
+		g.Expect(inv.Generation).To(Equal(int64(2)))
+
+		configMaps := &corev1.ConfigMapList{}
+		g.Expect(kubeClient.List(context.Background(), configMaps)).To(Succeed())
+		g.Expect(configMaps.Items).To(BeEmpty())
+
+		stored := NewInventory("app", "default")
+		g.Expect(storage.GetInventory(context.Background(), stored)).To(Succeed())
+		g.Expect(stored.ResourceVersion).ToNot(BeEmpty())
+	})
+}
@@ -0,0 +1,287 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stefanprodan/kustomizer/pkg/config"
+)
+
+// Event holds the outcome of an inventory operation.
+type Event struct {
+	// Operation is the name of the command that changed the inventory,
+	// e.g. 'apply', 'delete', 'rollback' or 'reconcile'.
+	Operation string `json:"operation"`
+
+	Inventory string   `json:"inventory"`
+	Namespace string   `json:"namespace"`
+	Source    string   `json:"source,omitempty"`
+	Revision  string   `json:"revision,omitempty"`
+	Artifacts []string `json:"artifacts,omitempty"`
+
+	// Changes holds the number of objects for each action, e.g. created, configured, deleted.
+	Changes map[string]int `json:"changes"`
+
+	// Error holds the error message if the operation failed.
+	Error string `json:"error,omitempty"`
+
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Succeeded returns true if the operation finished without errors.
+func (e *Event) Succeeded() bool {
+	return e.Error == ""
+}
+
+// Title returns a one line description of the operation outcome.
+func (e *Event) Title() string {
+	result := "succeeded"
+	if !e.Succeeded() {
+		result = "failed"
+	}
+	return fmt.Sprintf("%s %s/%s %s", e.Operation, e.Namespace, e.Inventory, result)
+}
+
+// Summary returns the number of objects for each action in alphabetical order,
+// e.g. '1 configured, 2 created'.
+func (e *Event) Summary() string {
+	var actions []string
+	for action, count := range e.Changes {
+		if count > 0 {
+			actions = append(actions, fmt.Sprintf("%d %s", count, action))
+		}
+	}
+	if len(actions) == 0 {
+		return "no changes"
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		return strings.SplitN(actions[i], " ", 2)[1] < strings.SplitN(actions[j], " ", 2)[1]
+	})
+	return strings.Join(actions, ", ")
+}
+
+// Notifier posts events to the configured webhooks.
+type Notifier struct {
+	webhooks []config.Webhook
+	retries  int
+	backoff  time.Duration
+	client   *http.Client
+}
+
+// NewNotifier returns a notifier for the given options.
+func NewNotifier(opts *config.NotificationOptions) *Notifier {
+	n := &Notifier{
+		backoff: time.Second,
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}
+	if opts != nil {
+		n.webhooks = opts.Webhooks
+		if opts.Retries != nil {
+			n.retries = *opts.Retries
+		}
+	}
+	return n
+}
+
+// Enabled returns true if at least one webhook is configured.
+func (n *Notifier) Enabled() bool {
+	return len(n.webhooks) > 0
+}
+
+// Post sends the event to all the webhooks, failed requests are retried with exponential backoff.
+// It returns an error listing the webhooks that could not be notified.
+func (n *Notifier) Post(ctx context.Context, event Event) error {
+	var failed []string
+	for _, webhook := range n.webhooks {
+		payload, err := NewPayload(webhook.Type, event)
+		if err != nil {
+			return err
+		}
+
+		if err := n.post(ctx, webhook, payload); err != nil {
+			failed = append(failed, fmt.Sprintf("%s webhook: %s", webhook.Type, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("notification failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (n *Notifier) post(ctx context.Context, webhook config.Webhook, payload []byte) error {
+	backoff := n.backoff
+	var err error
+	for attempt := 0; attempt <= n.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		var retry bool
+		retry, err = n.send(ctx, webhook, payload)
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// send posts the payload and returns true if the request can be retried.
+func (n *Notifier) send(ctx context.Context, webhook config.Webhook, payload []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range webhook.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("POST %s returned %s", req.URL.Redacted(), resp.Status)
+}
+
+// NewPayload returns the JSON body of the event in the given webhook format.
+func NewPayload(webhookType string, event Event) ([]byte, error) {
+	switch webhookType {
+	case config.WebhookGeneric:
+		return json.Marshal(event)
+	case config.WebhookSlack:
+		return json.Marshal(newSlackPayload(event))
+	case config.WebhookMSTeams:
+		return json.Marshal(newMSTeamsPayload(event))
+	default:
+		return nil, fmt.Errorf("unsupported webhook type '%s'", webhookType)
+	}
+}
+
+type slackPayload struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Fields []slackField `json:"fields"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+func newSlackPayload(event Event) slackPayload {
+	color := "good"
+	if !event.Succeeded() {
+		color = "danger"
+	}
+
+	var fields []slackField
+	for _, fact := range eventFacts(event) {
+		fields = append(fields, slackField{Title: fact.Name, Value: fact.Value, Short: len(fact.Value) < 40})
+	}
+
+	return slackPayload{
+		Text: event.Title(),
+		Attachments: []slackAttachment{
+			{Color: color, Fields: fields},
+		},
+	}
+}
+
+type msTeamsPayload struct {
+	Type       string           `json:"@type"`
+	Context    string           `json:"@context"`
+	ThemeColor string           `json:"themeColor"`
+	Summary    string           `json:"summary"`
+	Sections   []msTeamsSection `json:"sections"`
+}
+
+type msTeamsSection struct {
+	ActivityTitle string `json:"activityTitle"`
+	Facts         []fact `json:"facts"`
+}
+
+type fact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func newMSTeamsPayload(event Event) msTeamsPayload {
+	color := "2EB886"
+	if !event.Succeeded() {
+		color = "A30200"
+	}
+
+	return msTeamsPayload{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		ThemeColor: color,
+		Summary:    event.Title(),
+		Sections: []msTeamsSection{
+			{ActivityTitle: event.Title(), Facts: eventFacts(event)},
+		},
+	}
+}
+
+// eventFacts returns the event fields as name-value pairs, the empty fields are omitted.
+func eventFacts(event Event) []fact {
+	facts := []fact{
+		{Name: "Inventory", Value: event.Inventory},
+		{Name: "Namespace", Value: event.Namespace},
+	}
+	if event.Source != "" {
+		facts = append(facts, fact{Name: "Source", Value: event.Source})
+	}
+	if event.Revision != "" {
+		facts = append(facts, fact{Name: "Revision", Value: event.Revision})
+	}
+	if len(event.Artifacts) > 0 {
+		facts = append(facts, fact{Name: "Artifacts", Value: strings.Join(event.Artifacts, "\n")})
+	}
+	facts = append(facts, fact{Name: "Changes", Value: event.Summary()})
+	if event.Error != "" {
+		facts = append(facts, fact{Name: "Error", Value: event.Error})
+	}
+	return facts
+}
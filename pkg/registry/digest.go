@@ -0,0 +1,41 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Digest resolves the given artifact reference to its digest
+// in the format 'registry/org/repo@sha256:<hash>', without pulling the artifact content.
+func Digest(ctx context.Context, url string) (string, error) {
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return "", fmt.Errorf("parsing refernce failed: %w", err)
+	}
+
+	digest, err := crane.Digest(url, craneOptions(ctx)...)
+	if err != nil {
+		return "", err
+	}
+
+	return ref.Context().Digest(digest).String(), nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	// ManifestsFile is the name of the file holding all the manifests in a multi-doc YAML,
+	// for artifacts that don't preserve the manifests tree.
+	ManifestsFile = "all.yaml"
+
+	// KustomizationFile is the name of the file listing the manifests of an artifact tree,
+	// it's not part of the artifact content.
+	KustomizationFile = "kustomization.yaml"
+
+	// ageFileExt is appended to the name of the files encrypted with age.
+	ageFileExt = ".age"
+)
+
+// File is a file stored in an artifact.
+type File struct {
+	// Path is the slash separated path relative to the artifact root.
+	Path string
+
+	// Data is the content of the file.
+	Data []byte
+}
+
+// Content returns the multi-doc YAML of the manifests in the given files, in the order they are listed.
+// The kustomization at the root of the artifact tree is excluded, and the content of
+// single-file artifacts is returned as it is.
+func Content(files []File) string {
+	var manifests []File
+	for _, file := range files {
+		if file.Path != KustomizationFile {
+			manifests = append(manifests, file)
+		}
+	}
+
+	if len(manifests) == 1 {
+		return string(manifests[0].Data)
+	}
+
+	sb := new(strings.Builder)
+	for _, file := range manifests {
+		data := string(file.Data)
+		if strings.TrimSpace(data) == "" {
+			continue
+		}
+
+		sb.WriteString(data)
+		if !strings.HasSuffix(data, "\n") {
+			sb.WriteString("\n")
+		}
+		if !strings.HasSuffix(strings.TrimSpace(data), "---") {
+			sb.WriteString("---\n")
+		}
+	}
+	return sb.String()
+}
+
+// validatePath returns an error if the file path is not relative to the artifact root.
+func validatePath(p string) error {
+	clean := path.Clean(p)
+	if p == "" || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("invalid file path '%s', must be relative to the artifact root", p)
+	}
+	return nil
+}
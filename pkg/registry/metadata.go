@@ -25,16 +25,29 @@ const (
 	ChecksumAnnotation   = "kustomizer.dev/checksum"
 	CreatedAnnotation    = "kustomizer.dev/created"
 	EncryptedAnnotation  = "kustomizer.dev/encrypted"
+	LayoutAnnotation     = "kustomizer.dev/layout"
 	AgeEncryptionVersion = "age-encryption.org/v1"
 	SourceAnnotation     = "org.opencontainers.image.source"
 	RevisionAnnotation   = "org.opencontainers.image.revision"
 )
 
+// The layouts of the artifact content.
+const (
+	// FileLayout stores the manifests in a single multi-doc YAML file,
+	// artifacts without a layout annotation use this layout.
+	FileLayout = "file"
+
+	// TreeLayout stores each object in its own file, in a directory per namespace,
+	// along with a kustomization listing the files.
+	TreeLayout = "tree"
+)
+
 type Metadata struct {
 	Version        string `json:"version"`
 	Checksum       string `json:"checksum"`
 	Created        string `json:"created"`
 	Encrypted      string `json:"encrypted,omitempty"`
+	Layout         string `json:"layout,omitempty"`
 	Digest         string `json:"digest,omitempty"`
 	SourceURL      string `json:"source_url"`
 	SourceRevision string `json:"source_revision"`
@@ -51,6 +64,10 @@ func (m *Metadata) ToAnnotations() map[string]string {
 		annotations[EncryptedAnnotation] = m.Encrypted
 	}
 
+	if m.Layout != "" {
+		annotations[LayoutAnnotation] = m.Layout
+	}
+
 	if m.SourceURL != "" {
 		annotations[SourceAnnotation] = m.SourceURL
 	}
@@ -88,6 +105,15 @@ func GetMetadata(annotations map[string]string) (*Metadata, error) {
 		m.Encrypted = encrypted
 	}
 
+	m.Layout = FileLayout
+	if layout, ok := annotations[LayoutAnnotation]; ok {
+		if layout != FileLayout && layout != TreeLayout {
+			return nil, fmt.Errorf("unsupported artifact layout '%s' built by kustomizer/v%s, upgrade kustomizer to pull this artifact",
+				layout, version)
+		}
+		m.Layout = layout
+	}
+
 	if sourceURL, ok := annotations[SourceAnnotation]; ok {
 		m.SourceURL = sourceURL
 	}
@@ -0,0 +1,64 @@
+/*
+Copyright 2021 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestGetMetadata(t *testing.T) {
+	annotations := func(layout string) map[string]string {
+		a := map[string]string{
+			VersionAnnotation:  "2.2.0",
+			ChecksumAnnotation: "sha",
+			CreatedAnnotation:  "2021-11-01T00:00:00Z",
+		}
+		if layout != "" {
+			a[LayoutAnnotation] = layout
+		}
+		return a
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		layout      string
+		err         string
+	}{
+		{name: "defaults to the file layout", annotations: annotations(""), layout: FileLayout},
+		{name: "reads the tree layout", annotations: annotations(TreeLayout), layout: TreeLayout},
+		{name: "fails for unsupported layouts", annotations: annotations("zip"), err: "unsupported artifact layout 'zip'"},
+		{name: "fails without version", annotations: map[string]string{}, err: VersionAnnotation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			meta, err := GetMetadata(tt.annotations)
+			if tt.err != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.err))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(meta.Layout).To(Equal(tt.layout))
+			g.Expect(meta.ToAnnotations()).To(HaveKeyWithValue(LayoutAnnotation, tt.layout))
+		})
+	}
+}
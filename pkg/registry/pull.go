@@ -21,72 +21,87 @@ import (
 	"crypto/sha256"
 	"filippo.io/age"
 	"fmt"
+	"strings"
 
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
 )
 
+// Pull downloads the artifact and returns the multi-doc YAML of its manifests along with the artifact metadata.
 func Pull(ctx context.Context, url string, identities []age.Identity) (string, *Metadata, error) {
+	files, meta, err := PullFiles(ctx, url, identities)
+	if err != nil {
+		return "", meta, err
+	}
+
+	return Content(files), meta, nil
+}
+
+// PullFiles downloads the artifact and returns its files along with the artifact metadata.
+// The files are decrypted with the given age identities if the artifact is encrypted.
+func PullFiles(ctx context.Context, url string, identities []age.Identity) ([]File, *Metadata, error) {
 	ref, err := name.ParseReference(url)
 	if err != nil {
-		return "", nil, fmt.Errorf("parsing refernce failed: %w", err)
+		return nil, nil, fmt.Errorf("parsing refernce failed: %w", err)
 	}
 
 	img, err := crane.Pull(url, craneOptions(ctx)...)
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
 	manifest, err := img.Manifest()
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
 	digest, err := img.Digest()
 	if err != nil {
-		return "", nil, fmt.Errorf("parsing digest failed: %w", err)
+		return nil, nil, fmt.Errorf("parsing digest failed: %w", err)
 	}
 
 	meta, err := GetMetadata(manifest.Annotations)
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 	meta.Digest = ref.Context().Digest(digest.String()).String()
 
 	if meta.Encrypted != "" && len(identities) < 1 {
-		return "", meta, fmt.Errorf("encrypted artifact, you need to supply a private key for decryption")
+		return nil, meta, fmt.Errorf("encrypted artifact, you need to supply a private key for decryption")
 	}
 
 	layers, err := img.Layers()
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
 	if len(layers) < 1 {
-		return "", nil, fmt.Errorf("no layers found in image")
+		return nil, nil, fmt.Errorf("no layers found in image")
 	}
 
 	blob, err := layers[0].Uncompressed()
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
-	content, err := untarContent(blob)
+	files, err := untarContent(blob)
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
 	if meta.Encrypted == AgeEncryptionVersion && len(identities) > 0 {
-		plainContent, err := decrypt([]byte(content), identities)
-		if err != nil {
-			return "", nil, fmt.Errorf("failed to decrypt content: %w", err)
+		for i, file := range files {
+			plainData, err := decrypt(file.Data, identities)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to decrypt content: %w", err)
+			}
+			files[i] = File{Path: strings.TrimSuffix(file.Path, ageFileExt), Data: plainData}
 		}
-		content = string(plainContent)
 	}
 
-	if meta.Checksum != fmt.Sprintf("%x", sha256.Sum256([]byte(content))) {
-		return "", nil, fmt.Errorf("checksum mismatch")
+	if meta.Checksum != fmt.Sprintf("%x", sha256.Sum256([]byte(Content(files)))) {
+		return nil, nil, fmt.Errorf("checksum mismatch")
 	}
 
-	return content, meta, nil
+	return files, meta, nil
 }
@@ -18,6 +18,7 @@ package registry
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -30,7 +31,10 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 )
 
-func Push(ctx context.Context, url string, data []byte, meta *Metadata, recipients []age.Recipient) (string, error) {
+// Push packages the files in an OCI artifact and pushes it to the container registry,
+// the files are encrypted with age if recipients are specified.
+// It returns the digest URL of the artifact.
+func Push(ctx context.Context, url string, files []File, meta *Metadata, recipients []age.Recipient) (string, error) {
 	ref, err := name.ParseReference(url)
 	if err != nil {
 		return "", fmt.Errorf("parsing refernce failed: %w", err)
@@ -43,20 +47,22 @@ func Push(ctx context.Context, url string, data []byte, meta *Metadata, recipien
 	defer os.RemoveAll(tmpDir)
 
 	tarFile := filepath.Join(tmpDir, "all.tar")
-	dataFile := "all.yaml"
+	meta.Checksum = fmt.Sprintf("%x", sha256.Sum256([]byte(Content(files))))
 
 	if len(recipients) > 0 {
 		meta.Encrypted = AgeEncryptionVersion
-		encData, err := encrypt(data, recipients)
-		if err != nil {
-			return "", fmt.Errorf("failed to encrypt data with age: %w", err)
+		encFiles := make([]File, 0, len(files))
+		for _, file := range files {
+			encData, err := encrypt(file.Data, recipients)
+			if err != nil {
+				return "", fmt.Errorf("failed to encrypt data with age: %w", err)
+			}
+			encFiles = append(encFiles, File{Path: file.Path + ageFileExt, Data: encData})
 		}
-
-		dataFile = "all.yaml.age"
-		data = encData
+		files = encFiles
 	}
 
-	if err := tarContent(tarFile, dataFile, data); err != nil {
+	if err := tarContent(tarFile, files); err != nil {
 		return "", err
 	}
 
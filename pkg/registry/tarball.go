@@ -18,12 +18,14 @@ package registry
 
 import (
 	"archive/tar"
+	"fmt"
 	"io"
 	"os"
-	"strings"
+	"path"
 )
 
-func tarContent(tarPath string, name string, data []byte) error {
+// tarContent writes the files to a tarball in the given order.
+func tarContent(tarPath string, files []File) error {
 	tarFile, err := os.Create(tarPath)
 	if err != nil {
 		return err
@@ -32,41 +34,54 @@ func tarContent(tarPath string, name string, data []byte) error {
 	tw := tar.NewWriter(tarFile)
 	defer tw.Close()
 
-	header := &tar.Header{
-		Name: name,
-		Mode: 0600,
-		Size: int64(len(data)),
-	}
+	for _, file := range files {
+		if err := validatePath(file.Path); err != nil {
+			return err
+		}
 
-	if err := tw.WriteHeader(header); err != nil {
-		return err
-	}
+		header := &tar.Header{
+			Name: path.Clean(file.Path),
+			Mode: 0600,
+			Size: int64(len(file.Data)),
+		}
 
-	if _, err := tw.Write(data); err != nil {
-		return err
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if _, err := tw.Write(file.Data); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func untarContent(r io.Reader) (string, error) {
-	sb := new(strings.Builder)
+// untarContent returns the regular files from the tarball in the order they were written.
+func untarContent(r io.Reader) ([]File, error) {
+	var files []File
 	tr := tar.NewReader(r)
 	for {
 		header, err := tr.Next()
 		switch {
 		case err == io.EOF:
-			return sb.String(), nil
+			return files, nil
 		case err != nil:
-			return "", err
+			return nil, err
 		case header == nil:
 			continue
 		}
 
 		if header.Typeflag == tar.TypeReg {
-			if _, err := io.Copy(sb, tr); err != nil {
-				return "", err
+			if err := validatePath(header.Name); err != nil {
+				return nil, fmt.Errorf("tarball contains %w", err)
+			}
+
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
 			}
+			files = append(files, File{Path: path.Clean(header.Name), Data: data})
 		}
 	}
 }